@@ -0,0 +1,319 @@
+package pinger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS error.
+var (
+	ErrDNSMalformed = errors.New("malformed DNS response")
+	ErrDNSMismatch  = errors.New("DNS response does not match query")
+	ErrNoDNSName    = errors.New("no name to query")
+)
+
+const (
+	defaultDNSPort = "53"
+	dnsUDPSize     = 4096
+)
+
+var dnsRCodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// DNSConfig for a DNS() pinger.
+type DNSConfig struct {
+	Addr string // Address of DNS server, as host:port (port optional, default 53).
+
+	Name  string           // Name to query.
+	Type  dnsmessage.Type  // Type of query (optional, default A).
+	Class dnsmessage.Class // Class of query (optional, default IN).
+	TCP   bool             // TCP sends queries over TCP instead of UDP (optional).
+
+	// FailRCodes are response codes that fail the ping with a *DNSResponseError, such as dnsmessage.RCodeNameError (NXDOMAIN) (optional).
+	// By default, any response is a successful ping.
+	FailRCodes []dnsmessage.RCode
+
+	Source Source // Source of queries (optional).
+}
+
+// DNSDetail describes the response to a DNS() ping.
+type DNSDetail struct {
+	RCode         dnsmessage.RCode // Response code.
+	Answers       int              // Number of records in the answer section.
+	Truncated     bool             // Truncated is true if the response was truncated. Truncated responses are not retried over TCP.
+	Authoritative bool             // Authoritative is true if the server is an authority for the name.
+}
+
+// DNSResponseError is returned by a DNS() ping when the response code is one of DNSConfig.FailRCodes.
+type DNSResponseError struct {
+	Detail *DNSDetail // Detail of the response.
+}
+
+type dnsDriver struct {
+	config DNSConfig
+	name   dnsmessage.Name
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	seq uint32
+}
+
+// DNS pinger.
+// Each ping sends a query to the server and waits for the response, which is returned as the packet message.
+// The packet's *DNSDetail describes the response.
+//
+// For queries over TCP, each ping opens a new connection and the RTT excludes the time taken to connect.
+func DNS(cfg DNSConfig) (Pinger, error) {
+	if err := validateDNSConfig(&cfg); err != nil {
+		return nil, err
+	}
+	name, err := dnsmessage.NewName(cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	return New(&dnsDriver{config: cfg, name: name}), nil
+}
+
+func (d *dnsDriver) Address() net.Addr {
+	if d.config.TCP {
+		return tcpAddr(d.config.Addr)
+	}
+	return udpAddr(d.config.Addr)
+}
+
+func (d *dnsDriver) Connect(ctx context.Context) error {
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	return nil
+}
+
+func (d *dnsDriver) Disconnect() error {
+	d.cancel()
+	return nil
+}
+
+func (d *dnsDriver) Source() net.Addr {
+	return d.config.Source.addr()
+}
+
+func (d *dnsDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	rawc := make(chan RawPacket, 1)
+
+	go func() {
+		raw, err := d.send(ctx, timer)
+		if err != nil {
+			errc <- err
+		} else {
+			rawc <- raw
+		}
+	}()
+
+	select {
+	case <-d.ctx.Done():
+		return RawPacket{}, d.ctx.Err()
+	case <-ctx.Done():
+		return RawPacket{}, ctx.Err()
+	case err := <-errc:
+		return RawPacket{}, err
+	case raw := <-rawc:
+		return raw, nil
+	}
+}
+
+// parse a response to the query with the given ID.
+func (d *dnsDriver) parse(id uint16, b []byte) (*DNSDetail, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDNSMalformed, err)
+	}
+	if h.ID != id || !h.Response {
+		return nil, ErrDNSMismatch
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDNSMalformed, err)
+	}
+	if !strings.EqualFold(q.Name.String(), d.name.String()) || q.Type != d.config.Type || q.Class != d.config.Class {
+		return nil, ErrDNSMismatch
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDNSMalformed, err)
+	}
+
+	detail := &DNSDetail{
+		RCode:         h.RCode,
+		Truncated:     h.Truncated,
+		Authoritative: h.Authoritative,
+	}
+	for {
+		if _, err := p.AnswerHeader(); err == dnsmessage.ErrSectionDone {
+			break
+		} else if err != nil {
+			if h.Truncated {
+				// records may be cut short
+				break
+			}
+			return nil, fmt.Errorf("%w: %s", ErrDNSMalformed, err)
+		}
+		if err := p.SkipAnswer(); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDNSMalformed, err)
+		}
+		detail.Answers++
+	}
+	for _, rcode := range d.config.FailRCodes {
+		if rcode == h.RCode {
+			return detail, &DNSResponseError{Detail: detail}
+		}
+	}
+	return detail, nil
+}
+
+// query creates a query message with the given ID.
+func (d *dnsDriver) query(id uint16) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:               id,
+		RecursionDesired: true,
+	})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{
+		Name:  d.name,
+		Type:  d.config.Type,
+		Class: d.config.Class,
+	}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// read the response to the query with the given ID.
+// Over UDP, responses that do not match the query are ignored, as they may be late or spoofed.
+func (d *dnsDriver) read(conn net.Conn, id uint16) ([]byte, *DNSDetail, error) {
+	if d.config.TCP {
+		l := make([]byte, 2)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, nil, err
+		}
+		b := make([]byte, binary.BigEndian.Uint16(l))
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return nil, nil, err
+		}
+		detail, err := d.parse(id, b)
+		return b, detail, err
+	}
+
+	b := make([]byte, dnsUDPSize)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		detail, err := d.parse(id, b[:n])
+		if errors.Is(err, ErrDNSMismatch) || errors.Is(err, ErrDNSMalformed) {
+			continue
+		}
+		return b[:n], detail, err
+	}
+}
+
+func (d *dnsDriver) send(ctx context.Context, timer *Timer) (RawPacket, error) {
+	seq := int(atomic.AddUint32(&d.seq, 1) - 1)
+	id := uint16(rand.Intn(1 << 16))
+	query, err := d.query(id)
+	if err != nil {
+		return RawPacket{}, err
+	}
+
+	network := "udp"
+	if d.config.TCP {
+		network = "tcp"
+		l := make([]byte, 2)
+		binary.BigEndian.PutUint16(l, uint16(len(query)))
+		query = append(l, query...)
+	}
+	conn, err := d.config.Source.dialer(network).DialContext(ctx, network, d.config.Addr)
+	if err != nil {
+		return RawPacket{}, err
+	}
+	// close connection when the ping completes or is abandoned
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	timer.Start()
+	if _, err := conn.Write(query); err != nil {
+		return RawPacket{}, err
+	}
+	b, detail, err := d.read(conn, id)
+	timer.Stop()
+	if err != nil {
+		return RawPacket{}, err
+	}
+	return RawPacket{
+		Message: b,
+		Size:    len(b),
+		Seq:     seq,
+		Detail:  detail,
+	}, nil
+}
+
+func (e *DNSResponseError) Error() string {
+	name, ok := dnsRCodeNames[e.Detail.RCode]
+	if !ok {
+		name = fmt.Sprintf("RCODE%d", e.Detail.RCode)
+	}
+	return fmt.Sprintf("DNS response %s", name)
+}
+
+func validateDNSConfig(cfg *DNSConfig) error {
+	// Addr required
+	if cfg.Addr == "" {
+		return ErrNoAddress
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		// add the default port, unbracketing an IPv6 address to rebracket it with the port
+		host := cfg.Addr
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+		cfg.Addr = net.JoinHostPort(host, defaultDNSPort)
+	}
+	// Name required
+	if cfg.Name == "" {
+		return ErrNoDNSName
+	}
+	if !strings.HasSuffix(cfg.Name, ".") {
+		cfg.Name += "."
+	}
+	// Type optional
+	if cfg.Type == 0 {
+		cfg.Type = dnsmessage.TypeA
+	}
+	// Class optional
+	if cfg.Class == 0 {
+		cfg.Class = dnsmessage.ClassINET
+	}
+	return nil
+}
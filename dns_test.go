@@ -0,0 +1,177 @@
+package pinger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testDNSResponse responds to a query with an A record for example.com., or NXDOMAIN for any other name.
+func testDNSResponse(query []byte) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	h.Response = true
+	if q.Name.String() != "example.com." {
+		h.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, h)
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	if h.RCode == dnsmessage.RCodeSuccess {
+		b.AResource(dnsmessage.ResourceHeader{Name: q.Name, Class: q.Class, TTL: 60}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})
+	}
+	res, _ := b.Finish()
+	return res
+}
+
+func testDNSServer(a *assert.Assertions) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return "", func() {}
+	}
+	l, err := net.Listen("tcp", conn.LocalAddr().String())
+	if !a.Nil(err) {
+		conn.Close()
+		return "", func() {}
+	}
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(testDNSResponse(b[:n]), addr)
+		}
+	}()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			n := make([]byte, 2)
+			if _, err := io.ReadFull(c, n); err == nil {
+				b := make([]byte, binary.BigEndian.Uint16(n))
+				if _, err := io.ReadFull(c, b); err == nil {
+					res := testDNSResponse(b)
+					binary.BigEndian.PutUint16(n, uint16(len(res)))
+					c.Write(append(n, res...))
+				}
+			}
+			c.Close()
+		}
+	}()
+	return conn.LocalAddr().String(), func() {
+		conn.Close()
+		l.Close()
+	}
+}
+
+func Test_DNS(t *testing.T) {
+	a := assert.New(t)
+	addr, stop := testDNSServer(a)
+	defer stop()
+
+	for _, tcp := range []bool{false, true} {
+		pinger, err := DNS(DNSConfig{
+			Addr: addr,
+			Name: "example.com",
+			TCP:  tcp,
+		})
+		if !a.Nil(err) {
+			return
+		}
+		if !a.Nil(pinger.Connect(context.Background())) {
+			return
+		}
+		packet, err := pinger.Ping()
+		if a.Nil(err) {
+			a.Equal(0, packet.Seq)
+			a.Less(int64(0), int64(packet.RTT))
+			detail, ok := packet.Detail.(*DNSDetail)
+			if a.True(ok) {
+				a.Equal(dnsmessage.RCodeSuccess, detail.RCode)
+				a.Equal(1, detail.Answers)
+				a.False(detail.Truncated)
+			}
+		}
+		a.Nil(pinger.Disconnect())
+	}
+}
+
+func Test_DNS_FailRCodes(t *testing.T) {
+	a := assert.New(t)
+	addr, stop := testDNSServer(a)
+	defer stop()
+
+	pinger, err := DNS(DNSConfig{
+		Addr: addr,
+		Name: "example.net.",
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	packet, err := pinger.Ping()
+	if a.Nil(err) {
+		a.Equal(dnsmessage.RCodeNameError, packet.Detail.(*DNSDetail).RCode)
+	}
+	a.Nil(pinger.Disconnect())
+
+	pinger, err = DNS(DNSConfig{
+		Addr:       addr,
+		Name:       "example.net.",
+		FailRCodes: []dnsmessage.RCode{dnsmessage.RCodeNameError, dnsmessage.RCodeServerFailure},
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	_, err = pinger.Ping()
+	a.True(errors.Is(err, ClassDNS), err)
+	rcodeErr := &DNSResponseError{}
+	if a.True(errors.As(err, &rcodeErr)) {
+		a.Equal("DNS response NXDOMAIN", rcodeErr.Error())
+	}
+	a.Nil(pinger.Disconnect())
+}
+
+func Test_DNS_Addr(t *testing.T) {
+	a := assert.New(t)
+	testCases := []struct {
+		addr     string
+		expected string
+	}{
+		{"127.0.0.1", "127.0.0.1:53"},
+		{"127.0.0.1:5353", "127.0.0.1:5353"},
+		{"::1", "[::1]:53"},
+		{"[::1]", "[::1]:53"},
+		{"[::1]:5353", "[::1]:5353"},
+		{"localhost", "localhost:53"},
+	}
+	for _, tc := range testCases {
+		cfg := DNSConfig{Addr: tc.addr, Name: "example.com"}
+		if a.Nil(validateDNSConfig(&cfg), tc.addr) {
+			a.Equal(tc.expected, cfg.Addr)
+		}
+	}
+}
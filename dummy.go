@@ -3,6 +3,7 @@ package pinger
 import (
 	"context"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,6 +11,7 @@ type dummyDriver struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	errChance int
+	seq       uint32
 	wait      time.Duration
 }
 
@@ -40,12 +42,19 @@ func (d *dummyDriver) Disconnect() error {
 	return nil
 }
 
-func (d *dummyDriver) Ping(timer *Timer) (RawPacket, error) {
+func (d *dummyDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
 	raw := RawPacket{
 		Message: []byte{},
+		Seq:     int(atomic.AddUint32(&d.seq, 1) - 1),
 	}
 	timer.Start()
-	time.Sleep(d.wait)
-	timer.Stop()
+	select {
+	case <-d.ctx.Done():
+		return RawPacket{}, d.ctx.Err()
+	case <-ctx.Done():
+		return RawPacket{}, ctx.Err()
+	case <-time.After(d.wait):
+		timer.Stop()
+	}
 	return raw, nil
 }
@@ -5,14 +5,6 @@ import (
 	"time"
 )
 
-func bytesToTime(b []byte) time.Time {
-	var nsec int64
-	for i := uint8(0); i < 8; i++ {
-		nsec += int64(b[i]) << ((7 - i) * 8)
-	}
-	return time.Unix(nsec/1000000000, nsec%1000000000)
-}
-
 func bytesToInt(b []byte) int64 {
 	return int64(binary.BigEndian.Uint64(b))
 }
@@ -39,10 +39,14 @@ func (p *errorPinger) Disconnect() error {
 }
 
 func (p *errorPinger) Ping() (Packet, error) {
+	return p.PingContext(context.Background())
+}
+
+func (p *errorPinger) PingContext(ctx context.Context) (Packet, error) {
 	if p.hasError() {
-		return Packet{}, ErrForcedError
+		return Packet{}, Classify(ErrForcedError)
 	}
-	return p.next.Ping()
+	return p.next.PingContext(ctx)
 }
 
 func (p *errorPinger) hasError() bool {
@@ -0,0 +1,154 @@
+package pinger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// ErrorClass classifies the cause of a failed ping.
+// An ErrorClass is itself an error, so errors.Is(err, ClassTimeout) determines whether a ping timed out.
+type ErrorClass int
+
+// Error classes.
+const (
+	ClassUnknown     ErrorClass = iota // Unclassified error.
+	ClassTimeout                       // Ping timed out.
+	ClassRefused                       // Connection refused by host.
+	ClassUnreachable                   // Host or network unreachable.
+	ClassDNS                           // DNS lookup failed.
+	ClassTLS                           // TLS handshake or certificate verification failed.
+	ClassProtocol                      // Response did not conform to protocol.
+	ClassForced                        // Failure forced by Errors() middleware.
+	ClassCancelled                     // Ping or connection cancelled.
+	ClassAssertion                     // Response did not meet the pinger's success criteria.
+)
+
+// Error is returned by pingers when a ping fails.
+// Use errors.As to access the class of an error, or errors.Is to test for a class or underlying error.
+type Error struct {
+	Class ErrorClass // Class of error.
+	Err   error      // Underlying error.
+}
+
+// Classify wraps an error in an *Error describing its class.
+// If the error is already classified, it is returned as-is.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	pe := &Error{}
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &Error{
+		Class: classify(err),
+		Err:   err,
+	}
+}
+
+// ClassOf returns the class of an error.
+func ClassOf(err error) ErrorClass {
+	pe := &Error{}
+	if errors.As(err, &pe) {
+		return pe.Class
+	}
+	return classify(err)
+}
+
+func classify(err error) ErrorClass {
+	// drivers may wrap an ErrorClass to classify errors explicitly
+	var class ErrorClass
+	if errors.As(err, &class) {
+		return class
+	}
+
+	var (
+		dnsErr    *net.DNSError
+		rcodeErr  *DNSResponseError
+		icmpErr   *ICMPError
+		netErr    net.Error
+		certErr   x509.CertificateInvalidError
+		hostErr   x509.HostnameError
+		authErr   x509.UnknownAuthorityError
+		rootsErr  x509.SystemRootsError
+		recordErr tls.RecordHeaderError
+		expiryErr *TLSExpiryError
+		assertErr *HTTPAssertionError
+	)
+	switch {
+	case errors.Is(err, ErrForcedError):
+		return ClassForced
+	case errors.Is(err, ErrPingTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ClassCancelled
+	case errors.As(err, &assertErr):
+		return ClassAssertion
+	case errors.As(err, &dnsErr), errors.As(err, &rcodeErr):
+		return ClassDNS
+	case errors.Is(err, ErrDNSMalformed), errors.Is(err, ErrDNSMismatch):
+		return ClassProtocol
+	case errors.As(err, &certErr), errors.As(err, &hostErr), errors.As(err, &authErr), errors.As(err, &rootsErr), errors.As(err, &recordErr), errors.As(err, &expiryErr):
+		return ClassTLS
+	case errors.As(err, &icmpErr):
+		if errors.Is(err, ErrICMPParameterProblem) {
+			return ClassProtocol
+		}
+		return ClassUnreachable
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ClassRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return ClassUnreachable
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	}
+	return ClassUnknown
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Err)
+}
+
+// Is determines whether the error belongs to an ErrorClass.
+func (e *Error) Is(target error) bool {
+	c, ok := target.(ErrorClass)
+	return ok && c == e.Class
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (c ErrorClass) Error() string {
+	return c.String()
+}
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassTimeout:
+		return "timeout"
+	case ClassRefused:
+		return "refused"
+	case ClassUnreachable:
+		return "unreachable"
+	case ClassDNS:
+		return "dns"
+	case ClassTLS:
+		return "tls"
+	case ClassProtocol:
+		return "protocol"
+	case ClassForced:
+		return "forced"
+	case ClassCancelled:
+		return "cancelled"
+	case ClassAssertion:
+		return "assertion"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,45 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/ipv4"
+)
+
+func Test_Classify(t *testing.T) {
+	a := assert.New(t)
+
+	testCases := []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{errors.New("mystery"), ClassUnknown},
+		{ErrPingTimeout, ClassTimeout},
+		{context.DeadlineExceeded, ClassTimeout},
+		{context.Canceled, ClassCancelled},
+		{ErrForcedError, ClassForced},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ClassRefused},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, ClassUnreachable},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, ClassDNS},
+		{&ICMPError{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 1}, ClassUnreachable},
+		{&ICMPError{Type: ipv4.ICMPTypeParameterProblem}, ClassProtocol},
+		{fmt.Errorf("bad response: %w", ClassProtocol), ClassProtocol},
+		{&HTTPAssertionError{Err: ErrHTTPStatus, Expected: "200", Actual: "500"}, ClassAssertion},
+	}
+
+	for _, tc := range testCases {
+		err := Classify(tc.err)
+		a.Equal(tc.expected, ClassOf(err), tc.err.Error())
+		a.True(errors.Is(err, tc.expected))
+		a.True(errors.Is(err, tc.err))
+		a.Equal(err, Classify(err))
+	}
+	a.Nil(Classify(nil))
+}
@@ -1,14 +1,83 @@
 package pinger
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// HTTPConfig for an HTTPWithConfig() pinger.
+type HTTPConfig struct {
+	Method string      // Request method (optional, default GET).
+	URL    string      // Request URL.
+	Header http.Header // Header of requests (optional).
+	Host   string      // Host header, overriding the host of the URL (optional).
+	Body   []byte      // Body of requests (optional).
+
+	BasicAuth   *HTTPBasicAuth // BasicAuth credentials (optional).
+	BearerToken string         // BearerToken sent in the Authorization header (optional).
+
+	// Connections determines whether connections are reused between pings (optional, default keep-alive).
+	Connections HTTPConnectionMode
+
+	// NoRedirects returns redirect responses rather than following them (optional).
+	NoRedirects bool
+	// MaxRedirects is the maximum number of redirects to follow (optional, default 10).
+	MaxRedirects int
+
+	// Proxy returns the proxy for a request (optional, default http.ProxyFromEnvironment).
+	// Use http.ProxyURL to set a fixed proxy.
+	Proxy func(*http.Request) (*url.URL, error)
+	// TLS configuration of HTTPS connections, such as custom root CAs, client certificates or InsecureSkipVerify (optional).
+	TLS *tls.Config
+
+	// Client to send requests with (optional).
+	// The client is used as-is, so it cannot be combined with options that configure the client: Transport, Connections, Proxy, TLS, Source or redirects.
+	Client *http.Client
+	// Transport to send requests with (optional).
+	// The transport is used as-is, so it cannot be combined with options that configure the transport: Connections, Proxy, TLS or Source.
+	Transport http.RoundTripper
+
+	Source Source // Source of requests (optional).
+
+	// Assert success criteria of responses (optional).
+	// By default, any response is a successful ping.
+	Assert HTTPAssertions
+}
+
+// HTTPConnectionMode determines whether an HTTP() pinger reuses connections between pings.
+type HTTPConnectionMode int
+
+// HTTP connection modes.
+const (
+	// HTTPKeepAlive reuses idle connections, so the RTT of most pings only reflects request latency.
+	HTTPKeepAlive HTTPConnectionMode = iota
+	// HTTPFreshConnection opens a new connection for each ping, so the RTT includes connection setup.
+	HTTPFreshConnection
+)
+
+// HTTPBasicAuth credentials.
+type HTTPBasicAuth struct {
+	Username string
+	Password string
+}
+
+// Keep-alive period of HTTP connections, matching http.DefaultTransport.
+const httpKeepAlive = 30 * time.Second
+
+// Maximum number of redirects followed, matching http.Client.
+const defaultHTTPMaxRedirects = 10
+
 type httpAddr struct {
 	Method string
 	URL    *url.URL
@@ -20,6 +89,16 @@ type httpDriver struct {
 
 	addr   *httpAddr
 	client *http.Client
+	config HTTPConfig
+	seq    uint32
+}
+
+func errHTTPConfigConflict(a, b string) error {
+	return fmt.Errorf("HTTP %s cannot be combined with %s", a, b)
+}
+
+func errInvalidHTTPConnectionMode(m HTTPConnectionMode) error {
+	return fmt.Errorf("invalid HTTP connection mode %d", m)
 }
 
 func errInvalidHTTPMethod(m string) error {
@@ -27,36 +106,143 @@ func errInvalidHTTPMethod(m string) error {
 }
 
 // HTTP pinger.
-// The standard implementation supports GET or HEAD requests without authentication.
-// This is a simple pinger, and for more complex requirements you are better off writing a custom driver.
+// This is a simple pinger sending requests without a body or authentication.
+// For more complex requests, use HTTPWithConfig().
 func HTTP(method string, reqURL string) (Pinger, error) {
-	addrURL, err := url.Parse(reqURL)
+	return HTTPWithConfig(HTTPConfig{
+		Method: method,
+		URL:    reqURL,
+	})
+}
+
+// HTTPWithConfig creates an HTTP pinger with additional configuration.
+// See HTTP() for detail.
+func HTTPWithConfig(cfg HTTPConfig) (Pinger, error) {
+	addrURL, err := url.Parse(cfg.URL)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodGet
+	}
 	return httpWithAddr(httpAddr{
-		Method: method,
+		Method: cfg.Method,
 		URL:    addrURL,
-	})
+	}, cfg)
 }
 
-func httpWithAddr(addr httpAddr) (Pinger, error) {
-	switch addr.Method {
-	case http.MethodGet:
-		break
-	case http.MethodHead:
+func httpWithAddr(addr httpAddr, cfg HTTPConfig) (Pinger, error) {
+	if !validHTTPMethod(addr.Method) {
+		return nil, errInvalidHTTPMethod(addr.Method)
+	}
+	switch cfg.Connections {
+	case HTTPKeepAlive, HTTPFreshConnection:
 		break
 	default:
-		return nil, errInvalidHTTPMethod(addr.Method)
+		return nil, errInvalidHTTPConnectionMode(cfg.Connections)
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
 	}
-
 	p := &httpDriver{
 		addr:   &addr,
-		client: &http.Client{},
+		client: client,
+		config: cfg,
 	}
 	return New(p), nil
 }
 
+// newHTTPClient creates a client for an HTTP pinger, unless one is provided.
+func newHTTPClient(cfg HTTPConfig) (*http.Client, error) {
+	if cfg.Client != nil {
+		switch {
+		case cfg.Transport != nil:
+			return nil, errHTTPConfigConflict("Client", "Transport")
+		case cfg.NoRedirects, cfg.MaxRedirects != 0:
+			return nil, errHTTPConfigConflict("Client", "redirect options")
+		}
+		if err := checkHTTPTransportConflict("Client", cfg); err != nil {
+			return nil, err
+		}
+		return cfg.Client, nil
+	}
+
+	client := &http.Client{
+		CheckRedirect: httpRedirectPolicy(cfg),
+	}
+	if cfg.Transport != nil {
+		if err := checkHTTPTransportConflict("Transport", cfg); err != nil {
+			return nil, err
+		}
+		client.Transport = cfg.Transport
+		return client, nil
+	}
+	if cfg.Source.addr() == nil && cfg.Proxy == nil && cfg.TLS == nil && cfg.Connections == HTTPKeepAlive {
+		// share the default transport
+		return client, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Source.addr() != nil {
+		dialer := cfg.Source.dialer("tcp")
+		dialer.KeepAlive = httpKeepAlive
+		transport.DialContext = dialer.DialContext
+	}
+	if cfg.Proxy != nil {
+		transport.Proxy = cfg.Proxy
+	}
+	if cfg.TLS != nil {
+		transport.TLSClientConfig = cfg.TLS.Clone()
+	}
+	transport.DisableKeepAlives = cfg.Connections == HTTPFreshConnection
+	client.Transport = transport
+	return client, nil
+}
+
+// checkHTTPTransportConflict returns an error if transport options are set alongside a provided client or transport.
+func checkHTTPTransportConflict(name string, cfg HTTPConfig) error {
+	switch {
+	case cfg.Connections != HTTPKeepAlive:
+		return errHTTPConfigConflict(name, "Connections")
+	case cfg.Proxy != nil:
+		return errHTTPConfigConflict(name, "Proxy")
+	case cfg.TLS != nil:
+		return errHTTPConfigConflict(name, "TLS")
+	case cfg.Source.addr() != nil:
+		return errHTTPConfigConflict(name, "Source")
+	}
+	return nil
+}
+
+// httpRedirectPolicy creates a redirect policy compatible with http.Client.CheckRedirect.
+func httpRedirectPolicy(cfg HTTPConfig) func(*http.Request, []*http.Request) error {
+	max := cfg.MaxRedirects
+	if max <= 0 {
+		max = defaultHTTPMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if cfg.NoRedirects {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= max {
+			return errHTTPTooManyRedirects(max)
+		}
+		return nil
+	}
+}
+
+func errHTTPTooManyRedirects(max int) error {
+	return fmt.Errorf("stopped after %d redirects", max)
+}
+
+// validHTTPMethod determines whether a method is a valid HTTP token.
+func validHTTPMethod(m string) bool {
+	return m != "" && strings.IndexFunc(m, func(r rune) bool {
+		return r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, r)
+	}) < 0
+}
+
 func (a *httpAddr) Network() string {
 	return "http"
 }
@@ -69,6 +255,10 @@ func (d *httpDriver) Address() net.Addr {
 	return d.addr
 }
 
+func (d *httpDriver) Source() net.Addr {
+	return d.config.Source.addr()
+}
+
 func (d *httpDriver) Connect(ctx context.Context) error {
 	d.ctx, d.cancel = context.WithCancel(ctx)
 	return nil
@@ -79,14 +269,15 @@ func (d *httpDriver) Disconnect() error {
 	return nil
 }
 
-func (d *httpDriver) Ping(timer *Timer) (RawPacket, error) {
+func (d *httpDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	errc := make(chan error, 1)
 	rawc := make(chan RawPacket, 1)
-	defer close(errc)
-	defer close(rawc)
 
 	go func() {
-		raw, err := d.send(timer)
+		raw, err := d.send(ctx, timer)
 		if err != nil {
 			errc <- err
 		} else {
@@ -97,6 +288,8 @@ func (d *httpDriver) Ping(timer *Timer) (RawPacket, error) {
 	select {
 	case <-d.ctx.Done():
 		return RawPacket{}, d.ctx.Err()
+	case <-ctx.Done():
+		return RawPacket{}, ctx.Err()
 	case err := <-errc:
 		return RawPacket{}, err
 	case raw := <-rawc:
@@ -104,30 +297,84 @@ func (d *httpDriver) Ping(timer *Timer) (RawPacket, error) {
 	}
 }
 
-func (d *httpDriver) newRequest() *http.Request {
-	return &http.Request{
-		Method: d.addr.Method,
-		URL:    d.addr.URL,
+func (d *httpDriver) newRequest(ctx context.Context, trace *httpTrace) (*http.Request, error) {
+	var body io.Reader
+	if len(d.config.Body) > 0 {
+		body = bytes.NewReader(d.config.Body)
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.ClientTrace()), d.addr.Method, d.addr.URL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if d.config.Header != nil {
+		req.Header = d.config.Header.Clone()
+	}
+	if d.config.Host != "" {
+		req.Host = d.config.Host
 	}
+	if d.config.BasicAuth != nil {
+		req.SetBasicAuth(d.config.BasicAuth.Username, d.config.BasicAuth.Password)
+	}
+	if d.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+d.config.BearerToken)
+	}
+	return req, nil
 }
 
-func (d *httpDriver) send(timer *Timer) (RawPacket, error) {
-	req := d.newRequest()
+// readBody reads the response body, up to the maximum body size if set.
+func (d *httpDriver) readBody(res *http.Response) ([]byte, error) {
+	max := d.config.Assert.MaxBodySize
+	if max <= 0 {
+		return ioutil.ReadAll(res.Body)
+	}
+	tooLarge := errHTTPAssertion(ErrHTTPBodyTooLarge, "", fmt.Sprintf("at most %dB", max), fmt.Sprintf("more than %dB", max))
+	if res.ContentLength > max {
+		return nil, tooLarge
+	}
+	msg, err := ioutil.ReadAll(io.LimitReader(res.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(msg)) > max {
+		return nil, tooLarge
+	}
+	return msg, nil
+}
+
+func (d *httpDriver) send(ctx context.Context, timer *Timer) (RawPacket, error) {
+	seq := int(atomic.AddUint32(&d.seq, 1) - 1)
+	trace := newHTTPTrace()
+	req, err := d.newRequest(ctx, trace)
+	if err != nil {
+		return RawPacket{}, err
+	}
 	timer.Start()
 	res, err := d.client.Do(req)
 	timer.Stop()
 	if err != nil {
 		return RawPacket{}, err
 	}
+	defer res.Body.Close()
 
-	msg, err := ioutil.ReadAll(res.Body)
+	msg, err := d.readBody(res)
 	if err != nil {
 		return RawPacket{}, err
 	}
+	timing := trace.Timing()
+	timing.Transfer = time.Since(timer.Stopped)
+	if err := d.config.Assert.check(res, msg); err != nil {
+		return RawPacket{}, err
+	}
 	raw := RawPacket{
 		Message: msg,
 		Size:    len(msg),
 		TTL:     0,
+		Seq:     seq,
+		Detail: &HTTPDetail{
+			StatusCode: res.StatusCode,
+			Reused:     trace.Reused(),
+			Timing:     timing,
+		},
 	}
 	return raw, nil
 }
@@ -0,0 +1,203 @@
+package pinger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HTTP assertion error, wrapped by HTTPAssertionError.
+var (
+	ErrHTTPStatus       = errors.New("unexpected status code")
+	ErrHTTPHeader       = errors.New("unexpected header")
+	ErrHTTPBodyTooLarge = errors.New("body too large")
+	ErrHTTPBody         = errors.New("body did not match")
+	ErrHTTPJSON         = errors.New("JSON did not match")
+)
+
+// HTTPAssertions describe the success criteria of an HTTP() ping.
+// All criteria must be met for a ping to succeed.
+type HTTPAssertions struct {
+	// Status codes accepted (optional, default any).
+	// For example, []HTTPStatusRange{{200, 299}, {304, 304}} accepts any 2xx status or 304 Not Modified.
+	Status []HTTPStatusRange
+	// Headers required (optional).
+	// If a header's expected value is empty, the header need only be present.
+	Headers map[string]string
+	// MaxBodySize in bytes (optional).
+	// Larger bodies fail the ping without being read in full.
+	MaxBodySize int64
+	// BodyContains a substring (optional).
+	BodyContains string
+	// BodyMatches a regular expression (optional).
+	BodyMatches *regexp.Regexp
+	// JSON values required in the body, by path (optional).
+	// A path is a dot-separated list of object keys and array indices, such as "data.items.0.id".
+	// Expected values are compared after encoding them as JSON, so for example 1 and 1.0 are equal.
+	JSON map[string]interface{}
+}
+
+// HTTPStatusRange is an inclusive range of HTTP status codes.
+type HTTPStatusRange struct {
+	Min int
+	Max int
+}
+
+// HTTPAssertionError is returned by an HTTP() ping when the response does not meet HTTPAssertions.
+// Use errors.Is to test which assertion failed, such as ErrHTTPStatus.
+type HTTPAssertionError struct {
+	Err      error  // Err is the ErrHTTP* sentinel for the failed assertion.
+	Name     string // Name of the header or JSON path that did not match, if applicable.
+	Expected string // Expected value.
+	Actual   string // Actual value.
+}
+
+// check a response and its body against the assertions.
+func (a *HTTPAssertions) check(res *http.Response, body []byte) error {
+	if err := a.checkStatus(res.StatusCode); err != nil {
+		return err
+	}
+	for name, expected := range a.Headers {
+		values, ok := res.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return errHTTPAssertion(ErrHTTPHeader, name, quoteOrPresent(expected), "absent")
+		}
+		if expected != "" && !containsString(values, expected) {
+			return errHTTPAssertion(ErrHTTPHeader, name, strconv.Quote(expected), strconv.Quote(strings.Join(values, ", ")))
+		}
+	}
+	if a.BodyContains != "" && !bytes.Contains(body, []byte(a.BodyContains)) {
+		return errHTTPAssertion(ErrHTTPBody, "", fmt.Sprintf("substring %q", a.BodyContains), fmt.Sprintf("%dB body", len(body)))
+	}
+	if a.BodyMatches != nil && !a.BodyMatches.Match(body) {
+		return errHTTPAssertion(ErrHTTPBody, "", fmt.Sprintf("match for %s", a.BodyMatches), fmt.Sprintf("%dB body", len(body)))
+	}
+	if len(a.JSON) > 0 {
+		return a.checkJSON(body)
+	}
+	return nil
+}
+
+func (a *HTTPAssertions) checkJSON(body []byte) error {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return errHTTPAssertion(ErrHTTPJSON, "", "JSON body", err.Error())
+	}
+	for path, expected := range a.JSON {
+		// normalize expected value to the types produced by decoding
+		eb, err := json.Marshal(expected)
+		if err != nil {
+			return err
+		}
+		var ev interface{}
+		if err := json.Unmarshal(eb, &ev); err != nil {
+			return err
+		}
+		actual, ok := jsonPath(doc, path)
+		if !ok {
+			return errHTTPAssertion(ErrHTTPJSON, path, string(eb), "absent")
+		}
+		if !reflect.DeepEqual(ev, actual) {
+			ab, _ := json.Marshal(actual)
+			return errHTTPAssertion(ErrHTTPJSON, path, string(eb), string(ab))
+		}
+	}
+	return nil
+}
+
+func (a *HTTPAssertions) checkStatus(code int) error {
+	if len(a.Status) == 0 {
+		return nil
+	}
+	for _, r := range a.Status {
+		if r.Contains(code) {
+			return nil
+		}
+	}
+	expected := make([]string, len(a.Status))
+	for i, r := range a.Status {
+		expected[i] = r.String()
+	}
+	return errHTTPAssertion(ErrHTTPStatus, "", strings.Join(expected, ", "), strconv.Itoa(code))
+}
+
+// Contains determines whether a status code is within the range.
+func (r HTTPStatusRange) Contains(code int) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+func (r HTTPStatusRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+func (e *HTTPAssertionError) Error() string {
+	s := e.Err.Error()
+	if e.Name != "" {
+		s = fmt.Sprintf("%s %s", s, e.Name)
+	}
+	return fmt.Sprintf("%s (expected %s; actual %s)", s, e.Expected, e.Actual)
+}
+
+func (e *HTTPAssertionError) Unwrap() error {
+	return e.Err
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func errHTTPAssertion(err error, name, expected, actual string) error {
+	return &HTTPAssertionError{
+		Err:      err,
+		Name:     name,
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+// jsonPath finds the value at a dot-separated path in a decoded JSON document.
+func jsonPath(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			value, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func quoteOrPresent(s string) string {
+	if s == "" {
+		return "present"
+	}
+	return strconv.Quote(s)
+}
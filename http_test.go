@@ -2,8 +2,18 @@ package pinger
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,3 +56,278 @@ func Test_HTTP_HEAD(t *testing.T) {
 		a.Equal(0, packet.Size)
 	}
 }
+
+func Test_HTTP_Source(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}))
+	defer server.Close()
+
+	source := Source{Addr: net.IPv4(127, 0, 0, 1)}
+	if runtime.GOOS == "linux" {
+		source.Interface = "lo"
+	}
+	pinger, err := HTTPWithConfig(HTTPConfig{
+		Method: http.MethodGet,
+		URL:    server.URL,
+		Source: source,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	packet := doTestHTTP(a, pinger)
+	if packet != nil {
+		a.Equal(source, packet.Source)
+		a.Contains(string(packet.Message), "127.0.0.1:")
+	}
+}
+
+func Test_HTTP_Timing(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	// resolve localhost to include a DNS lookup
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	pinger, err := HTTP(http.MethodGet, "http://localhost:"+port)
+	if !a.Nil(err) {
+		return
+	}
+	pinger, stats := Track(pinger)
+	packet := doTestHTTP(a, pinger)
+	if packet == nil {
+		return
+	}
+	detail, ok := packet.Detail.(*HTTPDetail)
+	if !a.True(ok) {
+		return
+	}
+	a.Equal(http.StatusOK, detail.StatusCode)
+	a.Less(int64(0), int64(detail.Timing.DNS))
+	a.Less(int64(0), int64(detail.Timing.Connect))
+	a.Equal(time.Duration(0), detail.Timing.TLS)
+	a.LessOrEqual(10*time.Millisecond, detail.Timing.TTFB)
+	a.Less(detail.Timing.TTFB, packet.RTT)
+
+	report := stats.Calculate()
+	a.Equal(detail.Timing, report.MeanHTTPTiming)
+}
+
+func Test_HTTP_Assertions(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"ok","data":{"items":[{"id":1},{"id":2}]}}`))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		assert   HTTPAssertions
+		expected error
+	}{
+		{HTTPAssertions{}, nil},
+		{HTTPAssertions{Status: []HTTPStatusRange{{200, 299}}}, nil},
+		{HTTPAssertions{Status: []HTTPStatusRange{{200, 200}, {204, 204}}}, ErrHTTPStatus},
+		{HTTPAssertions{Headers: map[string]string{"content-type": "application/json"}}, nil},
+		{HTTPAssertions{Headers: map[string]string{"Content-Type": ""}}, nil},
+		{HTTPAssertions{Headers: map[string]string{"Content-Type": "text/html"}}, ErrHTTPHeader},
+		{HTTPAssertions{Headers: map[string]string{"X-Missing": ""}}, ErrHTTPHeader},
+		{HTTPAssertions{MaxBodySize: 1024}, nil},
+		{HTTPAssertions{MaxBodySize: 8}, ErrHTTPBodyTooLarge},
+		{HTTPAssertions{BodyContains: `"status":"ok"`}, nil},
+		{HTTPAssertions{BodyContains: "error"}, ErrHTTPBody},
+		{HTTPAssertions{BodyMatches: regexp.MustCompile(`"id":\d+`)}, nil},
+		{HTTPAssertions{BodyMatches: regexp.MustCompile(`"name"`)}, ErrHTTPBody},
+		{HTTPAssertions{JSON: map[string]interface{}{"status": "ok", "data.items.1.id": 2}}, nil},
+		{HTTPAssertions{JSON: map[string]interface{}{"data.items.1.id": 3}}, ErrHTTPJSON},
+		{HTTPAssertions{JSON: map[string]interface{}{"data.items.2.id": 3}}, ErrHTTPJSON},
+	}
+
+	for i, tc := range testCases {
+		pinger, err := HTTPWithConfig(HTTPConfig{
+			Method: http.MethodGet,
+			URL:    server.URL,
+			Assert: tc.assert,
+		})
+		if !a.Nil(err) {
+			return
+		}
+		if !a.Nil(pinger.Connect(context.Background())) {
+			return
+		}
+		_, err = pinger.Ping()
+		if tc.expected == nil {
+			a.Nil(err, i)
+		} else {
+			a.True(errors.Is(err, tc.expected), "%d: %v", i, err)
+			a.True(errors.Is(err, ClassAssertion), i)
+		}
+		a.Nil(pinger.Disconnect())
+	}
+}
+
+func Test_HTTP_Request(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		user, pass, _ := r.BasicAuth()
+		fmt.Fprintf(w, "%s %s %s %s:%s %s", r.Method, r.Host, r.Header.Get("X-Test"), user, pass, body)
+	}))
+	defer server.Close()
+
+	pinger, err := HTTPWithConfig(HTTPConfig{
+		Method:    http.MethodPut,
+		URL:       server.URL,
+		Header:    http.Header{"X-Test": []string{"test"}},
+		Host:      "example.com",
+		Body:      []byte("hello"),
+		BasicAuth: &HTTPBasicAuth{Username: "user", Password: "pass"},
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+	// body is sent with every request
+	for i := 0; i < 2; i++ {
+		packet, err := pinger.Ping()
+		if a.Nil(err) {
+			a.Equal("PUT example.com test user:pass hello", string(packet.Message))
+		}
+	}
+}
+
+func Test_HTTP_Redirects(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		cfg    HTTPConfig
+		status int
+	}{
+		{HTTPConfig{URL: server.URL + "/redirect"}, http.StatusOK},
+		{HTTPConfig{URL: server.URL + "/redirect", NoRedirects: true}, http.StatusFound},
+	}
+	for _, tc := range testCases {
+		pinger, err := HTTPWithConfig(tc.cfg)
+		if !a.Nil(err) {
+			return
+		}
+		packet := doTestHTTP(a, pinger)
+		if packet != nil {
+			a.Equal(tc.status, packet.Detail.(*HTTPDetail).StatusCode)
+		}
+	}
+}
+
+func Test_HTTP_TLS(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	testCases := []HTTPConfig{
+		{URL: server.URL, TLS: &tls.Config{RootCAs: roots}, BearerToken: "token"},
+		{URL: server.URL, TLS: &tls.Config{InsecureSkipVerify: true}},
+		{URL: server.URL, Client: server.Client()},
+		{URL: server.URL, Transport: server.Client().Transport},
+	}
+	for _, cfg := range testCases {
+		pinger, err := HTTPWithConfig(cfg)
+		if !a.Nil(err) {
+			return
+		}
+		packet := doTestHTTP(a, pinger)
+		if packet != nil && cfg.BearerToken != "" {
+			a.Equal("Bearer token", string(packet.Message))
+		}
+	}
+
+	// untrusted certificate
+	pinger, err := HTTP(http.MethodGet, server.URL)
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	_, err = pinger.Ping()
+	a.True(errors.Is(err, ClassTLS), err)
+	a.Nil(pinger.Disconnect())
+}
+
+func Test_HTTP_InvalidConfig(t *testing.T) {
+	a := assert.New(t)
+	testCases := []HTTPConfig{
+		{Method: "BAD METHOD", URL: "http://localhost"},
+		{URL: "http://localhost", Client: http.DefaultClient, Transport: http.DefaultTransport},
+		{URL: "http://localhost", Client: http.DefaultClient, NoRedirects: true},
+		{URL: "http://localhost", Client: http.DefaultClient, TLS: &tls.Config{}},
+		{URL: "http://localhost", Transport: http.DefaultTransport, Proxy: http.ProxyFromEnvironment},
+		{URL: "http://localhost", Transport: http.DefaultTransport, Source: Source{Interface: "lo"}},
+	}
+	for _, cfg := range testCases {
+		_, err := HTTPWithConfig(cfg)
+		a.NotNil(err)
+	}
+}
+
+func Test_HTTP_Connections(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		mode   HTTPConnectionMode
+		reused []bool
+	}{
+		{HTTPKeepAlive, []bool{false, true, true}},
+		{HTTPFreshConnection, []bool{false, false, false}},
+	}
+	for _, tc := range testCases {
+		pinger, err := HTTPWithConfig(HTTPConfig{
+			URL:         server.URL,
+			Connections: tc.mode,
+		})
+		if !a.Nil(err) {
+			return
+		}
+		if !a.Nil(pinger.Connect(context.Background())) {
+			return
+		}
+		for _, reused := range tc.reused {
+			packet, err := pinger.Ping()
+			if !a.Nil(err) {
+				break
+			}
+			detail := packet.Detail.(*HTTPDetail)
+			a.Equal(reused, detail.Reused)
+			a.Equal(reused, detail.Timing.Connect == 0)
+		}
+		a.Nil(pinger.Disconnect())
+	}
+
+	_, err := HTTPWithConfig(HTTPConfig{URL: server.URL, Connections: HTTPFreshConnection, Client: http.DefaultClient})
+	a.NotNil(err)
+}
@@ -0,0 +1,116 @@
+package pinger
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// HTTPTiming describes the duration of each phase of an HTTP request.
+// Phases that did not occur, such as connecting when a keep-alive connection is reused, are zero.
+type HTTPTiming struct {
+	DNS      time.Duration // DNS lookup of host.
+	Connect  time.Duration // Connect is the time taken to establish the TCP connection.
+	TLS      time.Duration // TLS handshake.
+	TTFB     time.Duration // TTFB (Time To First Byte) is the time between writing the request and receiving the first byte of the response.
+	Transfer time.Duration // Transfer is the time taken to read the response body.
+}
+
+// HTTPDetail describes the response to an HTTP() ping.
+type HTTPDetail struct {
+	StatusCode int        // Status code of response.
+	Reused     bool       // Reused is true if the request was sent on a keep-alive connection used by a previous request.
+	Timing     HTTPTiming // Timing of each phase of the request.
+}
+
+// httpTrace records the timing of each phase of an HTTP request.
+// The transport may call hooks from other goroutines, so access is synchronised.
+type httpTrace struct {
+	mut    *sync.Mutex
+	timing HTTPTiming
+	reused bool
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+}
+
+func newHTTPTrace() *httpTrace {
+	return &httpTrace{
+		mut: &sync.Mutex{},
+	}
+}
+
+// ClientTrace returns hooks to attach to the request's context.
+func (t *httpTrace) ClientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.start(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.done(&t.dnsStart, &t.timing.DNS)
+		},
+		ConnectStart: func(network, addr string) {
+			t.start(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.done(&t.connectStart, &t.timing.Connect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.start(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.done(&t.tlsStart, &t.timing.TLS)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mut.Lock()
+			defer t.mut.Unlock()
+			t.reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.start(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.done(&t.wroteRequest, &t.timing.TTFB)
+		},
+	}
+}
+
+// Reused determines whether the request was sent on a reused connection.
+func (t *httpTrace) Reused() bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.reused
+}
+
+// Timing of the request so far.
+func (t *httpTrace) Timing() HTTPTiming {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.timing
+}
+
+// done records the duration of a phase since its start time.
+func (t *httpTrace) done(start *time.Time, d *time.Duration) {
+	now := time.Now()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if !start.IsZero() {
+		*d = now.Sub(*start)
+	}
+}
+
+// start records the start time of a phase.
+// If a phase starts more than once, such as connecting to multiple addresses in parallel, the earliest start time is kept.
+func (t *httpTrace) start(start *time.Time) {
+	now := time.Now()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if start.IsZero() {
+		*start = now
+	}
+}
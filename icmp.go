@@ -3,11 +3,14 @@ package pinger
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
-	"syscall"
+	"os"
 	"time"
 
 	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // ICMP internal error.
@@ -15,10 +18,41 @@ var (
 	ErrICMPIgnoredPacket = errors.New("ignored packet")
 )
 
+// Raw socket error, shared by ICMP() and TCPSYN().
+var (
+	ErrRawSocketPermission = errors.New("raw socket requires root privileges or CAP_NET_RAW")
+)
+
+// ICMP error message, wrapped by ICMPError.
+var (
+	ErrICMPNetUnreachable      = errors.New("network unreachable")
+	ErrICMPHostUnreachable     = errors.New("host unreachable")
+	ErrICMPProtocolUnreachable = errors.New("protocol unreachable")
+	ErrICMPPortUnreachable     = errors.New("port unreachable")
+	ErrICMPProhibited          = errors.New("communication administratively prohibited")
+	ErrICMPUnreachable         = errors.New("destination unreachable")
+	ErrICMPPacketTooBig        = errors.New("packet too big")
+	ErrICMPTTLExceeded         = errors.New("TTL exceeded")
+	ErrICMPParameterProblem    = errors.New("parameter problem")
+)
+
 const (
 	defaultReadTimeout = 100 * time.Millisecond
 )
 
+// Limits of outgoing packet options.
+const (
+	maxICMPSize = 65507
+	maxTTL      = 255
+	maxDSCP     = 63
+)
+
+// Buffer sizes for reading ICMP packets.
+const (
+	readBufferSize = 512
+	oobBufferSize  = 128
+)
+
 // Size in bytes of echo message component.
 // 8 bytes represents an int64.
 const (
@@ -26,47 +60,108 @@ const (
 	trackerSize = 8
 )
 
+// ICMPMode determines the type of socket used by an ICMP() pinger.
+type ICMPMode int
+
+// ICMP socket modes.
+const (
+	// ICMPPrivileged uses a raw socket, which requires root privileges or CAP_NET_RAW.
+	ICMPPrivileged ICMPMode = iota
+	// ICMPUnprivileged uses a datagram socket.
+	// On Linux, the process' group must be within the net.ipv4.ping_group_range sysctl.
+	// ICMP error messages, such as destination unreachable, are not received in this mode.
+	ICMPUnprivileged
+	// ICMPAuto uses a raw socket if permitted, falling back to a datagram socket otherwise.
+	ICMPAuto
+)
+
 // ICMPConfig for an ICMP() pinger.
 type ICMPConfig struct {
 	Addr        *net.IPAddr   // Address of host.
 	ReadTimeout time.Duration // ReadTimeout for packet receiver (optional).
+	Mode        ICMPMode      // Mode of socket (optional, default privileged).
+
+	// KernelTimestamps uses receive times reported by the kernel (SO_TIMESTAMPNS) to measure RTT (optional).
+	// This avoids scheduling jitter between the socket and the pinger.
+	// Where the platform does not support kernel timestamps, userspace timing is used instead.
+	KernelTimestamps bool
+
+	// Size of echo payload in bytes (optional).
+	// The payload includes 16 bytes for a timestamp and tracker ID, which is also the minimum and default size.
+	Size int
+	// Pattern fills the echo payload after the timestamp and tracker ID (optional, default 0x01).
+	Pattern []byte
+	// TTL (IPv4) or hop limit (IPv6) of echo requests (optional, default set by OS).
+	TTL int
+	// DSCP (Differentiated Services Code Point) marking of echo requests (optional).
+	// This is set in the IPv4 TOS or IPv6 traffic class field.
+	DSCP int
+	// DontFragment sets the Don't Fragment bit on IPv4 and disables fragmentation on IPv6 (optional, Linux only).
+	// Echo requests that exceed the path MTU fail with ErrICMPPacketTooBig or a send error.
+	DontFragment bool
+
+	// Source of echo requests (optional).
+	// If a source address is set, it can only be used to ping hosts of the same IP version.
+	Source Source
 }
 
 type icmpDriver struct {
-	config ICMPConfig
+	engine *ICMPEngine
+	addr   *net.IPAddr
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
 	messageProvider *icmpMessageProvider
-	packetConn      *icmp.PacketConn
-	protocolHandler icmpProtocolHandler
-	chanRawPacket   chan RawPacket
+	sequences       *sequences
+	socket          *icmpSocket
+	dst             net.Addr
+}
+
+// ICMPError is returned by a ping when an ICMP error message is received in response to the echo request.
+// Use errors.Is to test for a specific error, such as ErrICMPHostUnreachable.
+type ICMPError struct {
+	Type icmp.Type // Type of ICMP message.
+	Code int       // Code of ICMP message.
+	From net.IP    // Address of the host or router that reported the error.
+}
+
+// icmpPacket describes a packet read from an ICMP socket.
+type icmpPacket struct {
+	Message []byte
+	TTL     int
+	Src     net.IP
+	OOB     []byte
 }
 
 type icmpProtocolHandler interface {
-	Listen(addr string) (*icmp.PacketConn, error)
+	Configure(*icmp.PacketConn, ICMPConfig) error
+	Listen(addr string, privileged bool) (*icmp.PacketConn, error)
+	Version() int
 	Parse([]byte) (*icmp.Message, error)
-	Read(*icmp.PacketConn) (b []byte, nb int, ttl int, err error)
+	Protocol() int
+	Read(conn *icmp.PacketConn, size int) (icmpPacket, error)
 	ReplyType() icmp.Type
 	RequestType() icmp.Type
 }
 
 // ICMP pinger.
-// This pinger requires the process to have root privileges.
+// By default, this pinger requires the process to have root privileges. See ICMPMode for alternatives.
+//
+// Each ICMP pinger opens its own socket. To ping many hosts, use an ICMPEngine to share sockets between pingers.
 func ICMP(cfg ICMPConfig) (Pinger, error) {
-	if err := validateICMPConfig(&cfg); err != nil {
+	if cfg.Addr == nil {
+		return nil, ErrNoAddress
+	}
+	e, err := NewICMPEngine(cfg)
+	if err != nil {
 		return nil, err
 	}
-	p := New(&icmpDriver{
-		config:          cfg,
-		protocolHandler: newProtocolHandler(cfg.Addr),
-	})
-	return p, nil
+	return e.Pinger(cfg.Addr)
 }
 
-func newProtocolHandler(addr *net.IPAddr) (h icmpProtocolHandler) {
-	if isIPv4(addr.IP) {
+func newProtocolHandler(ip net.IP) (h icmpProtocolHandler) {
+	if isIPv4(ip) {
 		h = &icmpIPv4Handler{}
 	} else {
 		h = &icmpIPv6Handler{}
@@ -75,155 +170,211 @@ func newProtocolHandler(addr *net.IPAddr) (h icmpProtocolHandler) {
 }
 
 func (d *icmpDriver) Address() net.Addr {
-	return d.config.Addr
+	return d.addr
 }
 
 func (d *icmpDriver) Connect(ctx context.Context) error {
-	c, err := d.protocolHandler.Listen("")
+	s, err := d.engine.acquire(d.addr.IP)
 	if err != nil {
 		return err
 	}
 
 	d.ctx, d.cancel = context.WithCancel(ctx)
-	d.messageProvider = newICMPMessageProvider(d.protocolHandler, d.config.Addr)
-	d.packetConn = c
-	d.chanRawPacket = make(chan RawPacket)
-
-	go d.recv()
+	d.sequences = newSequences()
+	d.socket = s
+	d.messageProvider = newICMPMessageProvider(s.protocolHandler.RequestType(), s.id, s.register(d.addr.IP, d.sequences), s.config.Size, s.config.Pattern)
+	if s.privileged {
+		d.dst = d.addr
+	} else {
+		// datagram sockets require a UDP address
+		d.dst = &net.UDPAddr{IP: d.addr.IP, Zone: d.addr.Zone}
+	}
 	return nil
 }
 
 func (d *icmpDriver) Disconnect() error {
 	d.cancel()
-	err := d.packetConn.Close()
-	return err
+	d.socket.unregister(d.messageProvider.tracker)
+	return d.engine.release(d.socket)
 }
 
-func (d *icmpDriver) Ping(timer *Timer) (RawPacket, error) {
+func (d *icmpDriver) Source() net.Addr {
+	return d.engine.config.Source.addr()
+}
+
+func (d *icmpDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	msg := d.messageProvider.Provide()
+	seq := msg.Body.(*icmp.Echo).Seq
+	replies := d.sequences.Add(seq)
+	sent := time.Now()
+
 	errc := make(chan error, 1)
-	defer close(errc)
 	go func() {
-		if err := d.send(timer); err != nil {
+		if err := d.socket.send(msg, d.dst); err != nil {
 			errc <- err
 		}
 	}()
 
-	for {
-		select {
-		case <-d.ctx.Done():
-			return RawPacket{}, d.ctx.Err()
-		case err := <-errc:
-			return RawPacket{}, err
-		case raw := <-d.chanRawPacket:
-			if err := d.handlePacket(raw); err != nil {
-				if isICMPIgnoredPacket(err) {
-					continue
-				}
-				return RawPacket{}, err
-			}
-			return raw, nil
+	var err error
+	select {
+	case <-d.ctx.Done():
+		err = d.ctx.Err()
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-d.socket.done:
+		err = d.socket.err
+	case err = <-errc:
+	case reply := <-replies:
+		if reply.Err != nil {
+			return RawPacket{}, reply.Err
 		}
+		stopICMPTimer(timer, sent, reply)
+		raw := reply.RawPacket
+		raw.Seq = seq
+		raw.Duplicates, raw.Late = d.sequences.Strays()
+		return raw, nil
 	}
+	d.sequences.Abandon(seq)
+	return RawPacket{}, err
 }
 
-func (d *icmpDriver) handlePacket(raw RawPacket) error {
-	msg, err := d.protocolHandler.Parse(raw.Message)
-	if err != nil {
-		return err
-	}
-	echo := msg.Body.(*icmp.Echo)
-	// ignore if not echo reply
-	if msg.Type != d.protocolHandler.ReplyType() {
-		return ErrICMPIgnoredPacket
-	}
-	// ignore if id mismatched
-	if echo.ID != d.messageProvider.id {
-		return ErrICMPIgnoredPacket
-	}
-
-	track, _, err := d.messageProvider.ReadData(msg)
-	if err != nil {
-		return err
-	}
-	// ignore if tracker mismatched
-	if track != d.messageProvider.tracker {
-		return ErrICMPIgnoredPacket
+// stopICMPTimer times a ping from the local time its request was sent to the time the reply was received.
+// The timestamp embedded in the echo request has no monotonic clock reading, so it is not used to measure RTT.
+// Kernel timestamps are wall clock times, so if the wall clock steps during a ping, the reply is timed in userspace instead.
+func stopICMPTimer(timer *Timer, sent time.Time, reply seqReply) {
+	timer.Started = sent
+	timer.Stopped = reply.Received
+	timer.Clock = reply.Clock
+	if reply.Clock == ClockKernel {
+		// the kernel receives a reply before it is read, so its RTT cannot exceed the monotonic elapsed time
+		if rtt := reply.Received.Sub(sent); rtt < 0 || rtt > time.Since(sent) {
+			timer.Stopped = time.Now()
+			timer.Clock = ClockUserspace
+		}
 	}
+}
 
-	// TODO: use ReadData 2nd return to set RTT
-	return nil
+func (e *ICMPError) Error() string {
+	return fmt.Sprintf("%s from %s (code %d)", e.Unwrap(), e.From, e.Code)
 }
 
-func (d *icmpDriver) recv() error {
-	defer close(d.chanRawPacket)
-	for {
-		select {
-		case <-d.ctx.Done():
-			return d.ctx.Err()
-		default:
-			raw, err := d.recvPacket()
-			if netErr, ok := err.(*net.OpError); ok {
-				if netErr.Timeout() {
-					continue
-				} else {
-					return err
-				}
-			}
-			d.chanRawPacket <- raw
+// Unwrap returns the error's ErrICMP* sentinel.
+func (e *ICMPError) Unwrap() error {
+	switch e.Type {
+	case ipv4.ICMPTypeDestinationUnreachable:
+		switch e.Code {
+		case 0, 6, 11:
+			return ErrICMPNetUnreachable
+		case 1, 7, 12:
+			return ErrICMPHostUnreachable
+		case 2:
+			return ErrICMPProtocolUnreachable
+		case 3:
+			return ErrICMPPortUnreachable
+		case 4:
+			return ErrICMPPacketTooBig
+		case 9, 10, 13:
+			return ErrICMPProhibited
+		}
+		return ErrICMPUnreachable
+	case ipv6.ICMPTypeDestinationUnreachable:
+		switch e.Code {
+		case 0:
+			return ErrICMPNetUnreachable
+		case 1, 5, 6:
+			return ErrICMPProhibited
+		case 3:
+			return ErrICMPHostUnreachable
+		case 4:
+			return ErrICMPPortUnreachable
 		}
+		return ErrICMPUnreachable
+	case ipv6.ICMPTypePacketTooBig:
+		return ErrICMPPacketTooBig
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		return ErrICMPTTLExceeded
+	case ipv4.ICMPTypeParameterProblem, ipv6.ICMPTypeParameterProblem:
+		return ErrICMPParameterProblem
 	}
+	return ErrICMPUnreachable
 }
 
-func (d *icmpDriver) recvPacket() (RawPacket, error) {
-	if err := d.packetConn.SetReadDeadline(time.Now().Add(d.config.ReadTimeout)); err != nil {
-		return RawPacket{}, err
-	}
-	b, nb, ttl, err := d.protocolHandler.Read(d.packetConn)
-	if err != nil {
-		return RawPacket{}, err
-	}
-	raw := RawPacket{
-		Message: b,
-		Size:    nb,
-		TTL:     time.Duration(ttl),
-	}
-	return raw, nil
+func isPermissionError(err error) bool {
+	return errors.Is(err, os.ErrPermission)
 }
 
-func (d *icmpDriver) send(timer *Timer) error {
-	msg := d.messageProvider.Provide()
-	msgBytes, err := msg.Marshal(nil)
-	if err != nil {
-		return err
+// rawSocketError returns ErrRawSocketPermission if err was caused by a lack of privileges to open a raw socket.
+func rawSocketError(err error) error {
+	if isPermissionError(err) {
+		return ErrRawSocketPermission
 	}
+	return err
+}
+
+func errInvalidICMPMode(m ICMPMode) error {
+	return fmt.Errorf("invalid ICMP mode %d", m)
+}
+
+func errInvalidICMPOption(name string, value, min, max int) error {
+	return fmt.Errorf("invalid ICMP %s %d (must be %d-%d)", name, value, min, max)
+}
 
-	timer.Start()
-	for {
-		_, err := d.packetConn.WriteTo(msgBytes, d.config.Addr)
-		if err != nil {
-			netErr, ok := err.(*net.OpError)
-			if ok && netErr.Err == syscall.ENOBUFS {
-				continue
-			}
-			return err
+// readMsg reads a packet, its source and its socket control messages from c.
+func readMsg(c net.PacketConn, b, oob []byte) (n, oobn int, src net.IP, err error) {
+	switch c := c.(type) {
+	case *net.IPConn:
+		var addr *net.IPAddr
+		if n, oobn, _, addr, err = c.ReadMsgIP(b, oob); addr != nil {
+			src = addr.IP
 		}
-		timer.Stop()
-		return nil
+	case *net.UDPConn:
+		var addr *net.UDPAddr
+		if n, oobn, _, addr, err = c.ReadMsgUDP(b, oob); addr != nil {
+			src = addr.IP
+		}
+	default:
+		n, _, err = c.ReadFrom(b)
 	}
+	return
 }
 
-func isICMPIgnoredPacket(err error) bool {
-	return err == ErrICMPIgnoredPacket
+// underlyingConn returns the socket connection wrapped by an ICMP packet connection.
+func underlyingConn(c *icmp.PacketConn) net.PacketConn {
+	if p4 := c.IPv4PacketConn(); p4 != nil {
+		return p4.PacketConn
+	}
+	return c.IPv6PacketConn().PacketConn
 }
 
 func validateICMPConfig(cfg *ICMPConfig) error {
-	// Addr required
-	if cfg.Addr == nil {
-		return ErrNoAddress
+	// Mode optional
+	switch cfg.Mode {
+	case ICMPPrivileged, ICMPUnprivileged, ICMPAuto:
+		break
+	default:
+		return errInvalidICMPMode(cfg.Mode)
 	}
 	// ReadTimeout optional
 	if cfg.ReadTimeout == 0 {
 		cfg.ReadTimeout = defaultReadTimeout
 	}
+	// Size optional
+	if cfg.Size == 0 {
+		cfg.Size = timeSize + trackerSize
+	} else if cfg.Size < timeSize+trackerSize || cfg.Size > maxICMPSize {
+		return errInvalidICMPOption("size", cfg.Size, timeSize+trackerSize, maxICMPSize)
+	}
+	// Pattern optional
+	if len(cfg.Pattern) == 0 {
+		cfg.Pattern = []byte{1}
+	}
+	// TTL optional
+	if cfg.TTL < 0 || cfg.TTL > maxTTL {
+		return errInvalidICMPOption("TTL", cfg.TTL, 0, maxTTL)
+	}
+	// DSCP optional
+	if cfg.DSCP < 0 || cfg.DSCP > maxDSCP {
+		return errInvalidICMPOption("DSCP", cfg.DSCP, 0, maxDSCP)
+	}
 	return nil
 }
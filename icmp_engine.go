@@ -0,0 +1,83 @@
+package pinger
+
+import (
+	"net"
+	"sync"
+)
+
+// ICMPEngine shares ICMP sockets between many pingers.
+// The engine opens one socket per address family when the first of its pingers connects, and closes it when the last disconnects.
+// Replies are demultiplexed to each pinger, so pinging many hosts does not require a socket and receiver per host.
+type ICMPEngine struct {
+	config ICMPConfig
+
+	mut     *sync.Mutex
+	sockets map[int]*icmpSocket
+}
+
+// NewICMPEngine creates an engine for ICMP pingers.
+// The config's socket options apply to all pingers created by the engine. Addr is ignored; see Pinger().
+func NewICMPEngine(cfg ICMPConfig) (*ICMPEngine, error) {
+	if err := validateICMPConfig(&cfg); err != nil {
+		return nil, err
+	}
+	e := &ICMPEngine{
+		config:  cfg,
+		mut:     &sync.Mutex{},
+		sockets: map[int]*icmpSocket{},
+	}
+	return e, nil
+}
+
+// Pinger creates an ICMP pinger for a host, using the engine's sockets.
+func (e *ICMPEngine) Pinger(addr *net.IPAddr) (Pinger, error) {
+	if addr == nil {
+		return nil, ErrNoAddress
+	}
+	p := New(&icmpDriver{
+		engine: e,
+		addr:   addr,
+	})
+	return p, nil
+}
+
+// acquire the socket for an IP address' family, opening it if necessary.
+func (e *ICMPEngine) acquire(ip net.IP) (*icmpSocket, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	family := 6
+	if isIPv4(ip) {
+		family = 4
+	}
+	s, ok := e.sockets[family]
+	if ok && s.closed() {
+		// replace a socket whose receiver has failed; its remaining pingers release it as they disconnect
+		delete(e.sockets, family)
+		ok = false
+	}
+	if !ok {
+		var err error
+		if s, err = openICMPSocket(e.config, newProtocolHandler(ip)); err != nil {
+			return nil, err
+		}
+		e.sockets[family] = s
+	}
+	s.refs++
+	return s, nil
+}
+
+// release a socket, closing it if it is no longer used.
+func (e *ICMPEngine) release(s *icmpSocket) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	for family, es := range e.sockets {
+		if es == s {
+			delete(e.sockets, family)
+		}
+	}
+	return s.close()
+}
@@ -1,15 +1,39 @@
 package pinger
 
 import (
+	"net"
+
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 )
 
 type icmpIPv4Handler struct{}
 
-func (h *icmpIPv4Handler) Listen(addr string) (conn *icmp.PacketConn, err error) {
+func (h *icmpIPv4Handler) Configure(conn *icmp.PacketConn, cfg ICMPConfig) error {
+	p := conn.IPv4PacketConn()
+	if cfg.TTL > 0 {
+		if err := p.SetTTL(cfg.TTL); err != nil {
+			return err
+		}
+	}
+	if cfg.DSCP > 0 {
+		if err := p.SetTOS(cfg.DSCP << 2); err != nil {
+			return err
+		}
+	}
+	if cfg.DontFragment {
+		return setDontFragment(underlyingConn(conn), 4)
+	}
+	return nil
+}
+
+func (h *icmpIPv4Handler) Listen(addr string, privileged bool) (conn *icmp.PacketConn, err error) {
+	network := "ip4:icmp"
+	if !privileged {
+		network = "udp4"
+	}
 	ok := false
-	if conn, err = icmp.ListenPacket("ip4:icmp", addr); err == nil {
+	if conn, err = icmp.ListenPacket(network, addr); err == nil {
 		ok = true
 		err = conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
 	}
@@ -21,15 +45,35 @@ func (h *icmpIPv4Handler) Listen(addr string) (conn *icmp.PacketConn, err error)
 }
 
 func (h *icmpIPv4Handler) Parse(b []byte) (*icmp.Message, error) {
-	return icmp.ParseMessage(1, b)
+	return icmp.ParseMessage(h.Protocol(), b)
 }
 
-func (h *icmpIPv4Handler) Read(conn *icmp.PacketConn) (b []byte, nb int, ttl int, err error) {
-	b = make([]byte, 512)
-	var cm *ipv4.ControlMessage
-	nb, cm, _, err = conn.IPv4PacketConn().ReadFrom(b)
-	if cm != nil {
-		ttl = cm.TTL
+func (h *icmpIPv4Handler) Protocol() int {
+	return 1
+}
+
+func (h *icmpIPv4Handler) Read(conn *icmp.PacketConn, size int) (pkt icmpPacket, err error) {
+	b := make([]byte, size)
+	oob := make([]byte, oobBufferSize)
+	c := underlyingConn(conn)
+	nb, oobn, src, err := readMsg(c, b, oob)
+	if err != nil {
+		return
+	}
+	// raw sockets include the IPv4 header
+	if _, ok := c.(*net.IPConn); ok && nb > 0 {
+		if hl := int(b[0]&0x0f) << 2; hl <= nb {
+			nb = copy(b, b[hl:nb])
+		}
+	}
+	pkt = icmpPacket{
+		Message: b[:nb],
+		Src:     src,
+		OOB:     oob[:oobn],
+	}
+	cm := &ipv4.ControlMessage{}
+	if cm.Parse(pkt.OOB) == nil {
+		pkt.TTL = cm.TTL
 	}
 	return
 }
@@ -41,3 +85,7 @@ func (h *icmpIPv4Handler) ReplyType() icmp.Type {
 func (h *icmpIPv4Handler) RequestType() icmp.Type {
 	return ipv4.ICMPTypeEcho
 }
+
+func (h *icmpIPv4Handler) Version() int {
+	return 4
+}
@@ -7,9 +7,31 @@ import (
 
 type icmpIPv6Handler struct{}
 
-func (h *icmpIPv6Handler) Listen(addr string) (conn *icmp.PacketConn, err error) {
+func (h *icmpIPv6Handler) Configure(conn *icmp.PacketConn, cfg ICMPConfig) error {
+	p := conn.IPv6PacketConn()
+	if cfg.TTL > 0 {
+		if err := p.SetHopLimit(cfg.TTL); err != nil {
+			return err
+		}
+	}
+	if cfg.DSCP > 0 {
+		if err := p.SetTrafficClass(cfg.DSCP << 2); err != nil {
+			return err
+		}
+	}
+	if cfg.DontFragment {
+		return setDontFragment(underlyingConn(conn), 6)
+	}
+	return nil
+}
+
+func (h *icmpIPv6Handler) Listen(addr string, privileged bool) (conn *icmp.PacketConn, err error) {
+	network := "ip6:ipv6-icmp"
+	if !privileged {
+		network = "udp6"
+	}
 	ok := false
-	if conn, err = icmp.ListenPacket("ip6:ipv6-icmp", addr); err == nil {
+	if conn, err = icmp.ListenPacket(network, addr); err == nil {
 		ok = true
 		err = conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
 	}
@@ -21,15 +43,28 @@ func (h *icmpIPv6Handler) Listen(addr string) (conn *icmp.PacketConn, err error)
 }
 
 func (h *icmpIPv6Handler) Parse(b []byte) (*icmp.Message, error) {
-	return icmp.ParseMessage(58, b)
+	return icmp.ParseMessage(h.Protocol(), b)
 }
 
-func (h *icmpIPv6Handler) Read(conn *icmp.PacketConn) (b []byte, nb int, ttl int, err error) {
-	b = make([]byte, 512)
-	var cm *ipv6.ControlMessage
-	nb, cm, _, err = conn.IPv6PacketConn().ReadFrom(b)
-	if cm != nil {
-		ttl = cm.HopLimit
+func (h *icmpIPv6Handler) Protocol() int {
+	return 58
+}
+
+func (h *icmpIPv6Handler) Read(conn *icmp.PacketConn, size int) (pkt icmpPacket, err error) {
+	b := make([]byte, size)
+	oob := make([]byte, oobBufferSize)
+	nb, oobn, src, err := readMsg(underlyingConn(conn), b, oob)
+	if err != nil {
+		return
+	}
+	pkt = icmpPacket{
+		Message: b[:nb],
+		Src:     src,
+		OOB:     oob[:oobn],
+	}
+	cm := &ipv6.ControlMessage{}
+	if cm.Parse(pkt.OOB) == nil {
+		pkt.TTL = cm.HopLimit
 	}
 	return
 }
@@ -41,3 +76,7 @@ func (h *icmpIPv6Handler) ReplyType() icmp.Type {
 func (h *icmpIPv6Handler) RequestType() icmp.Type {
 	return ipv6.ICMPTypeEchoRequest
 }
+
+func (h *icmpIPv6Handler) Version() int {
+	return 6
+}
@@ -1,15 +1,15 @@
 package pinger
 
 import (
-	"bytes"
 	"fmt"
 	"math"
-	"math/rand"
 	"net"
 	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 type icmpMessageProvider struct {
@@ -19,30 +19,23 @@ type icmpMessageProvider struct {
 	msgType icmp.Type
 	seq     int
 	tracker int64
-}
 
-func newICMPMessageProvider(h icmpProtocolHandler, addr *net.IPAddr) *icmpMessageProvider {
-	src := rand.NewSource(time.Now().UnixNano())
-	rng := rand.New(src)
+	size    int
+	pattern []byte
+}
 
+func newICMPMessageProvider(msgType icmp.Type, id int, tracker int64, size int, pattern []byte) *icmpMessageProvider {
 	return &icmpMessageProvider{
 		mut: &sync.Mutex{},
 
-		id:      rng.Intn(math.MaxInt16),
-		msgType: h.RequestType(),
+		id:      id,
+		msgType: msgType,
 		seq:     0,
-		tracker: rng.Int63n(math.MaxInt64),
-	}
-}
+		tracker: tracker,
 
-func (p *icmpMessageProvider) ReadData(msg *icmp.Message) (int64, time.Time, error) {
-	echo := msg.Body.(*icmp.Echo)
-	if ld := len(echo.Data); ld < timeSize+trackerSize {
-		return 0, time.Unix(0, 0), errICMPReplyTooShort(timeSize+trackerSize, ld)
+		size:    size,
+		pattern: pattern,
 	}
-	timestamp := bytesToTime(echo.Data[:timeSize])
-	tracker := bytesToInt(echo.Data[timeSize:(timeSize + trackerSize)])
-	return tracker, timestamp, nil
 }
 
 func (p *icmpMessageProvider) Provide() *icmp.Message {
@@ -59,7 +52,7 @@ func (p *icmpMessageProvider) Provide() *icmp.Message {
 	}
 	p.WriteData(msg)
 
-	p.seq++
+	p.seq = (p.seq + 1) % (math.MaxUint16 + 1)
 	return msg
 }
 
@@ -68,12 +61,66 @@ func (p *icmpMessageProvider) WriteData(msg *icmp.Message) {
 	data := timeToBytes(time.Now())
 	// data[8:] is tracker id
 	data = append(data, intToBytes(p.tracker)...)
-	if ld := (timeSize + trackerSize) - len(data); ld > 0 {
-		data = append(data, bytes.Repeat([]byte{1}, ld)...)
+	// data[16:] is filled with pattern
+	for i := 0; len(data) < p.size; i++ {
+		data = append(data, p.pattern[i%len(p.pattern)])
 	}
 	msg.Body.(*icmp.Echo).Data = data
 }
 
+// icmpQuote describes the original packet quoted in an ICMP error message.
+type icmpQuote struct {
+	Dst      net.IP // Destination of the original packet.
+	Protocol int    // Protocol of the original packet's payload.
+	Payload  []byte // Original packet's payload, starting with the transport header. This is likely to be truncated.
+}
+
+// parseICMPQuote parses the original packet quoted in the body of an ICMP error message.
+func parseICMPQuote(msg *icmp.Message) (*icmpQuote, bool) {
+	var data []byte
+	switch body := msg.Body.(type) {
+	case *icmp.DstUnreach:
+		data = body.Data
+	case *icmp.PacketTooBig:
+		data = body.Data
+	case *icmp.ParamProb:
+		data = body.Data
+	case *icmp.TimeExceeded:
+		data = body.Data
+	default:
+		return nil, false
+	}
+	if len(data) < 1 {
+		return nil, false
+	}
+
+	switch data[0] >> 4 {
+	case ipv4.Version:
+		h, err := icmp.ParseIPv4Header(data)
+		if err != nil || h.Len > len(data) {
+			return nil, false
+		}
+		return &icmpQuote{Dst: h.Dst, Protocol: h.Protocol, Payload: data[h.Len:]}, true
+	case ipv6.Version:
+		h, err := ipv6.ParseHeader(data)
+		if err != nil {
+			return nil, false
+		}
+		// extension headers are not supported
+		return &icmpQuote{Dst: h.Dst, Protocol: h.NextHeader, Payload: data[ipv6.HeaderLen:]}, true
+	}
+	return nil, false
+}
+
+// readEchoTracker reads the tracker ID embedded in an echo message.
+// The embedded timestamp is not read, as pings are timed from the local time they were sent.
+func readEchoTracker(echo *icmp.Echo) (int64, error) {
+	if ld := len(echo.Data); ld < timeSize+trackerSize {
+		return 0, errICMPReplyTooShort(timeSize+trackerSize, ld)
+	}
+	return bytesToInt(echo.Data[timeSize:(timeSize + trackerSize)]), nil
+}
+
 func errICMPReplyTooShort(expected, actual int) error {
 	return fmt.Errorf("reply too short (expected %dB; actual %dB)", expected, actual)
 }
@@ -0,0 +1,292 @@
+package pinger
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// icmpSocket reads and writes ICMP packets for any number of pingers.
+// Replies are dispatched to the pinger that sent the request by the tracker ID embedded in the echo data.
+type icmpSocket struct {
+	config ICMPConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	packetConn      *icmp.PacketConn
+	protocolHandler icmpProtocolHandler
+
+	id               int
+	privileged       bool
+	replyID          int
+	kernelTimestamps bool
+
+	mut       *sync.Mutex
+	rng       *rand.Rand
+	endpoints map[int64]*icmpEndpoint
+	refs      int
+}
+
+// icmpEndpoint receives replies for a single pinger.
+type icmpEndpoint struct {
+	addr      net.IP
+	sequences *sequences
+}
+
+func openICMPSocket(cfg ICMPConfig, h icmpProtocolHandler) (*icmpSocket, error) {
+	s := &icmpSocket{
+		config:          cfg,
+		done:            make(chan struct{}),
+		protocolHandler: h,
+		mut:             &sync.Mutex{},
+		rng:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		endpoints:       map[int64]*icmpEndpoint{},
+	}
+
+	c, privileged, err := s.listen()
+	if err != nil {
+		return nil, err
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.packetConn = c
+	s.privileged = privileged
+	s.id = s.rng.Intn(math.MaxInt16)
+	if privileged {
+		s.replyID = s.id
+	} else {
+		// the kernel rewrites the echo ID of datagram sockets to the socket's local port
+		s.replyID = underlyingConn(c).LocalAddr().(*net.UDPAddr).Port
+	}
+	if err := h.Configure(c, cfg); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if cfg.KernelTimestamps {
+		// fall back to userspace timing if unsupported
+		s.kernelTimestamps = enableKernelTimestamps(underlyingConn(c)) == nil
+	}
+
+	go s.recv()
+	return s, nil
+}
+
+func (s *icmpSocket) close() error {
+	s.cancel()
+	return s.packetConn.Close()
+}
+
+// closed determines whether the socket has stopped receiving.
+func (s *icmpSocket) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// endpoint finds the endpoint that registered a tracker ID.
+func (s *icmpSocket) endpoint(track int64) *icmpEndpoint {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.endpoints[track]
+}
+
+// endpointByAddr finds an endpoint pinging an address which is waiting for a reply to a sequence number.
+func (s *icmpSocket) endpointByAddr(ip net.IP, seq int) *icmpEndpoint {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for _, e := range s.endpoints {
+		if e.addr.Equal(ip) && e.sequences.Pending(seq) {
+			return e
+		}
+	}
+	return nil
+}
+
+// handlePacket matches a packet to the endpoint and sequence number of the echo request it responds to.
+// Echo replies are matched by their tracker ID. ICMP error messages are matched by the echo request they quote.
+func (s *icmpSocket) handlePacket(pkt icmpPacket, reply *seqReply) (*icmpEndpoint, int, error) {
+	msg, err := s.protocolHandler.Parse(pkt.Message)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if msg.Type == s.protocolHandler.ReplyType() {
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok {
+			return nil, 0, ErrICMPIgnoredPacket
+		}
+		// ignore if id mismatched
+		if echo.ID != s.replyID {
+			return nil, 0, ErrICMPIgnoredPacket
+		}
+		track, err := readEchoTracker(echo)
+		if err != nil {
+			return nil, 0, err
+		}
+		return s.endpoint(track), echo.Seq, nil
+	}
+
+	// ignore if not an error in response to an echo request
+	quote, ok := parseICMPQuote(msg)
+	if !ok || quote.Protocol != s.protocolHandler.Protocol() {
+		return nil, 0, ErrICMPIgnoredPacket
+	}
+	quoted, err := s.protocolHandler.Parse(quote.Payload)
+	if err != nil || quoted.Type != s.protocolHandler.RequestType() {
+		return nil, 0, ErrICMPIgnoredPacket
+	}
+	echo, ok := quoted.Body.(*icmp.Echo)
+	if !ok || echo.ID != s.replyID {
+		return nil, 0, ErrICMPIgnoredPacket
+	}
+	reply.Err = &ICMPError{
+		Type: msg.Type,
+		Code: msg.Code,
+		From: pkt.Src,
+	}
+	if track, err := readEchoTracker(echo); err == nil {
+		return s.endpoint(track), echo.Seq, nil
+	}
+	// quote is too short to include the tracker ID
+	return s.endpointByAddr(quote.Dst, echo.Seq), echo.Seq, nil
+}
+
+func (s *icmpSocket) listen() (c *icmp.PacketConn, privileged bool, err error) {
+	addr, err := s.config.Source.listenAddr(s.protocolHandler.Version())
+	if err != nil {
+		return nil, false, err
+	}
+	switch s.config.Mode {
+	case ICMPUnprivileged:
+		c, err = s.protocolHandler.Listen(addr, false)
+		privileged = false
+	case ICMPAuto:
+		if c, err = s.protocolHandler.Listen(addr, true); err != nil && isPermissionError(err) {
+			c, err = s.protocolHandler.Listen(addr, false)
+			privileged = false
+		} else {
+			privileged = true
+		}
+	default:
+		c, err = s.protocolHandler.Listen(addr, true)
+		err = rawSocketError(err)
+		privileged = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if s.config.Source.Interface != "" {
+		if err := bindToDevice(underlyingConn(c), s.config.Source.Interface); err != nil {
+			c.Close()
+			return nil, false, err
+		}
+	}
+	return c, privileged, nil
+}
+
+func (s *icmpSocket) recv() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.err = s.ctx.Err()
+			return
+		default:
+			pkt, reply, err := s.recvPacket()
+			if err != nil {
+				if netErr, ok := err.(*net.OpError); ok && netErr.Timeout() {
+					continue
+				}
+				s.err = err
+				return
+			}
+			e, seq, err := s.handlePacket(pkt, &reply)
+			if err != nil || e == nil {
+				// packets that cannot be matched to a ping are dropped
+				continue
+			}
+			e.sequences.Dispatch(seq, reply)
+		}
+	}
+}
+
+func (s *icmpSocket) recvPacket() (icmpPacket, seqReply, error) {
+	if err := s.packetConn.SetReadDeadline(time.Now().Add(s.config.ReadTimeout)); err != nil {
+		return icmpPacket{}, seqReply{}, err
+	}
+	pkt, err := s.protocolHandler.Read(s.packetConn, readBufferSize+s.config.Size)
+	// take receive time as close to the socket read as possible
+	received := time.Now()
+	if err != nil {
+		return icmpPacket{}, seqReply{}, err
+	}
+	reply := seqReply{
+		RawPacket: RawPacket{
+			Message: pkt.Message,
+			Size:    len(pkt.Message),
+			TTL:     time.Duration(pkt.TTL),
+		},
+		Received: received,
+		Clock:    ClockUserspace,
+	}
+	if s.kernelTimestamps {
+		if t, ok := kernelTimestamp(pkt.OOB); ok {
+			reply.Received = t
+			reply.Clock = ClockKernel
+		}
+	}
+	return pkt, reply, nil
+}
+
+// register an endpoint to receive replies from an address.
+// Returns the tracker ID the endpoint must embed in its echo requests.
+func (s *icmpSocket) register(addr net.IP, sequences *sequences) int64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for {
+		track := s.rng.Int63n(math.MaxInt64)
+		if _, exists := s.endpoints[track]; !exists {
+			s.endpoints[track] = &icmpEndpoint{
+				addr:      addr,
+				sequences: sequences,
+			}
+			return track
+		}
+	}
+}
+
+func (s *icmpSocket) send(msg *icmp.Message, dst net.Addr) error {
+	msgBytes, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, err := s.packetConn.WriteTo(msgBytes, dst)
+		if err != nil {
+			netErr, ok := err.(*net.OpError)
+			if ok && netErr.Err == syscall.ENOBUFS {
+				continue
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+func (s *icmpSocket) unregister(track int64) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	delete(s.endpoints, track)
+}
@@ -0,0 +1,112 @@
+package pinger
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func testICMPSocket() *icmpSocket {
+	return &icmpSocket{
+		protocolHandler: &icmpIPv4Handler{},
+		id:              1234,
+		replyID:         1234,
+		mut:             &sync.Mutex{},
+		rng:             rand.New(rand.NewSource(0)),
+		endpoints:       map[int64]*icmpEndpoint{},
+	}
+}
+
+// testICMPv4Error creates an ICMP error message quoting an echo request, truncated to quoteLen bytes of ICMP.
+func testICMPv4Error(a *assert.Assertions, typ icmp.Type, code int, dst net.IP, request *icmp.Message, quoteLen int) []byte {
+	req, err := request.Marshal(nil)
+	if !a.Nil(err) {
+		return nil
+	}
+	if quoteLen < len(req) {
+		req = req[:quoteLen]
+	}
+	h := &ipv4.Header{
+		Version:  ipv4.Version,
+		Len:      ipv4.HeaderLen,
+		TotalLen: ipv4.HeaderLen + len(req),
+		TTL:      1,
+		Protocol: 1,
+		Src:      net.ParseIP("10.0.0.1"),
+		Dst:      dst,
+	}
+	hb, err := h.Marshal()
+	if !a.Nil(err) {
+		return nil
+	}
+	var body icmp.MessageBody
+	switch typ {
+	case ipv4.ICMPTypeTimeExceeded:
+		body = &icmp.TimeExceeded{Data: append(hb, req...)}
+	default:
+		body = &icmp.DstUnreach{Data: append(hb, req...)}
+	}
+	msg := &icmp.Message{Type: typ, Code: code, Body: body}
+	b, err := msg.Marshal(nil)
+	a.Nil(err)
+	return b
+}
+
+func Test_ICMPSocket_Errors(t *testing.T) {
+	a := assert.New(t)
+	s := testICMPSocket()
+	dst := net.ParseIP("192.0.2.1")
+	router := net.ParseIP("198.51.100.1")
+	sequences := newSequences()
+	provider := newICMPMessageProvider(ipv4.ICMPTypeEcho, s.id, s.register(dst, sequences), 16, []byte{1})
+
+	testCases := []struct {
+		typ      icmp.Type
+		code     int
+		quoteLen int
+		expected error
+	}{
+		{ipv4.ICMPTypeDestinationUnreachable, 1, 64, ErrICMPHostUnreachable},
+		{ipv4.ICMPTypeDestinationUnreachable, 3, 64, ErrICMPPortUnreachable},
+		{ipv4.ICMPTypeTimeExceeded, 0, 64, ErrICMPTTLExceeded},
+		// quote without tracker is matched by destination and sequence number
+		{ipv4.ICMPTypeDestinationUnreachable, 0, 8, ErrICMPNetUnreachable},
+	}
+
+	for _, tc := range testCases {
+		request := provider.Provide()
+		seq := request.Body.(*icmp.Echo).Seq
+		sequences.Add(seq)
+		b := testICMPv4Error(a, tc.typ, tc.code, dst, request, tc.quoteLen)
+
+		reply := seqReply{}
+		e, matchedSeq, err := s.handlePacket(icmpPacket{Message: b, Src: router}, &reply)
+		if !a.Nil(err) {
+			continue
+		}
+		a.NotNil(e)
+		a.Equal(seq, matchedSeq)
+		a.True(errors.Is(reply.Err, tc.expected), reply.Err)
+		icmpErr := &ICMPError{}
+		if a.True(errors.As(reply.Err, &icmpErr)) {
+			a.True(router.Equal(icmpErr.From))
+		}
+	}
+}
+
+func Test_ICMPSocket_IgnoreForeignErrors(t *testing.T) {
+	a := assert.New(t)
+	s := testICMPSocket()
+	// echo request from another process
+	request := newICMPMessageProvider(ipv4.ICMPTypeEcho, 4321, 1, 16, []byte{1}).Provide()
+	b := testICMPv4Error(a, ipv4.ICMPTypeDestinationUnreachable, 1, net.ParseIP("192.0.2.1"), request, 64)
+
+	_, _, err := s.handlePacket(icmpPacket{Message: b}, &seqReply{})
+	a.Equal(ErrICMPIgnoredPacket, err)
+}
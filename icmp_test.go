@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -48,7 +51,11 @@ func Test_ICMP_IPv4(t *testing.T) {
 	if !a.Nil(err) {
 		return
 	}
-	doTestICMP(a, pinger)
+	packet := doTestICMP(a, pinger)
+	if packet != nil {
+		a.Less(time.Duration(0), packet.RTT)
+		a.False(packet.Sent.IsZero())
+	}
 }
 
 func Test_ICMP_IPv6(t *testing.T) {
@@ -63,5 +70,241 @@ func Test_ICMP_IPv6(t *testing.T) {
 	if !a.Nil(err) {
 		return
 	}
+	packet := doTestICMP(a, pinger)
+	if packet != nil {
+		a.Less(time.Duration(0), packet.RTT)
+		a.False(packet.Sent.IsZero())
+	}
+}
+
+func Test_ICMP_KernelTimestamps(t *testing.T) {
+	a := assert.New(t)
+	addr, err := net.ResolveIPAddr("ip6", "::1")
+	if !a.Nil(err) {
+		return
+	}
+	pinger, err := ICMP(ICMPConfig{
+		Addr:             addr,
+		KernelTimestamps: true,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	packet := doTestICMP(a, pinger)
+	if packet != nil && runtime.GOOS == "linux" {
+		a.Equal(ClockKernel, packet.Clock)
+	}
+}
+
+func Test_ICMP_Unprivileged(t *testing.T) {
+	a := assert.New(t)
+	addr, err := net.ResolveIPAddr("ip6", "::1")
+	if !a.Nil(err) {
+		return
+	}
+	pinger, err := ICMP(ICMPConfig{
+		Addr: addr,
+		Mode: ICMPUnprivileged,
+	})
+	if !a.Nil(err) {
+		return
+	}
 	doTestICMP(a, pinger)
 }
+
+func Test_ICMP_Concurrent(t *testing.T) {
+	a := assert.New(t)
+	addr, err := net.ResolveIPAddr("ip6", "::1")
+	if !a.Nil(err) {
+		return
+	}
+	icmpPinger, err := ICMP(ICMPConfig{
+		Addr: addr,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	pinger, stats := Track(icmpPinger)
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			pinger.Ping()
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	report := stats.Calculate()
+	a.Equal(10, report.NumSuccessful)
+}
+
+func Test_ICMPEngine(t *testing.T) {
+	a := assert.New(t)
+	engine, err := NewICMPEngine(ICMPConfig{})
+	if !a.Nil(err) {
+		return
+	}
+
+	hosts := []string{"127.0.0.1", "127.0.0.2", "::1"}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(hosts))
+	for _, host := range hosts {
+		addr := &net.IPAddr{IP: net.ParseIP(host)}
+		pinger, err := engine.Pinger(addr)
+		if !a.Nil(err) {
+			return
+		}
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				packet := doTestICMP(a, pinger)
+				if packet != nil {
+					a.Equal(addr, packet.Address)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_ICMPEngine_Reopen(t *testing.T) {
+	a := assert.New(t)
+	engine, err := NewICMPEngine(ICMPConfig{})
+	if !a.Nil(err) {
+		return
+	}
+	addr := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	dead, err := engine.Pinger(addr)
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(dead.Connect(context.Background())) {
+		return
+	}
+	// the socket fails while a pinger is still connected
+	s := engine.sockets[4]
+	s.packetConn.Close()
+	<-s.done
+	a.NotNil(s.err)
+
+	pinger, err := engine.Pinger(addr)
+	if !a.Nil(err) {
+		return
+	}
+	packet := doTestICMP(a, pinger)
+	if packet != nil {
+		a.Equal(addr, packet.Address)
+	}
+	a.NotEqual(s, engine.sockets[4])
+	// closing the failed socket again reports an error
+	a.NotNil(dead.Disconnect())
+	a.Empty(engine.sockets)
+}
+
+func Test_stopICMPTimer(t *testing.T) {
+	a := assert.New(t)
+	sent := time.Now()
+	time.Sleep(time.Millisecond)
+
+	// kernel timestamps have no monotonic clock reading, so a wall clock step during the ping distorts them
+	testCases := []seqReply{
+		{Received: time.Now().Add(-time.Hour).Round(0), Clock: ClockKernel},
+		{Received: time.Now().Add(time.Hour).Round(0), Clock: ClockKernel},
+	}
+	for i, reply := range testCases {
+		timer := &Timer{}
+		stopICMPTimer(timer, sent, reply)
+		a.Equal(sent, timer.Started, i)
+		a.Equal(ClockUserspace, timer.Clock, i)
+		a.Less(int64(0), int64(timer.Elapsed()), i)
+		a.Greater(int64(time.Second), int64(timer.Elapsed()), i)
+	}
+
+	// userspace timestamps and consistent kernel timestamps are kept
+	for _, clock := range []ClockSource{ClockUserspace, ClockKernel} {
+		received := time.Now()
+		if clock == ClockKernel {
+			received = received.Round(0)
+		}
+		timer := &Timer{}
+		stopICMPTimer(timer, sent, seqReply{Received: received, Clock: clock})
+		a.Equal(clock, timer.Clock)
+		a.Equal(received, timer.Stopped)
+		a.Less(int64(0), int64(timer.Elapsed()))
+	}
+}
+
+func Test_ICMP_Options(t *testing.T) {
+	a := assert.New(t)
+	addr, err := net.ResolveIPAddr("ip6", "::1")
+	if !a.Nil(err) {
+		return
+	}
+	pinger, err := ICMP(ICMPConfig{
+		Addr:         addr,
+		Size:         1000,
+		Pattern:      []byte{0xde, 0xad},
+		TTL:          5,
+		DSCP:         46,
+		DontFragment: runtime.GOOS == "linux",
+	})
+	if !a.Nil(err) {
+		return
+	}
+	packet := doTestICMP(a, pinger)
+	if packet != nil {
+		// 8 byte echo header + payload
+		a.Equal(1008, packet.Size)
+		a.Equal([]byte{0xde, 0xad, 0xde}, packet.Message[24:27])
+	}
+}
+
+func Test_ICMP_InvalidOptions(t *testing.T) {
+	a := assert.New(t)
+	addr := &net.IPAddr{IP: net.IPv6loopback}
+	for _, cfg := range []ICMPConfig{
+		{Addr: addr, Size: 8},
+		{Addr: addr, TTL: 256},
+		{Addr: addr, DSCP: 64},
+	} {
+		_, err := ICMP(cfg)
+		a.NotNil(err)
+	}
+}
+
+func Test_ICMP_Source(t *testing.T) {
+	a := assert.New(t)
+	source := Source{Addr: net.IPv6loopback}
+	if runtime.GOOS == "linux" {
+		source.Interface = "lo"
+	}
+	pinger, err := ICMP(ICMPConfig{
+		Addr:   &net.IPAddr{IP: net.IPv6loopback},
+		Source: source,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	packet := doTestICMP(a, pinger)
+	if packet != nil {
+		a.Equal(source, packet.Source)
+	}
+
+	// source address must match IP version of host
+	pinger, err = ICMP(ICMPConfig{
+		Addr:   &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)},
+		Source: source,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	a.NotNil(pinger.Connect(context.Background()))
+}
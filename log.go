@@ -46,10 +46,14 @@ func (p *pingerLogger) Disconnect() error {
 }
 
 func (p *pingerLogger) Ping() (Packet, error) {
-	pkt, err := p.next.Ping()
+	return p.PingContext(context.Background())
+}
+
+func (p *pingerLogger) PingContext(ctx context.Context) (Packet, error) {
+	pkt, err := p.next.PingContext(ctx)
 	lc := p.log.Context(p.context).Label("func", "ping")
 	if err != nil {
-		lc.Error(err)
+		lc.Label("class", ClassOf(err).String()).Error(err)
 	} else {
 		lc.Trace(p.formatPacket(pkt))
 	}
@@ -57,5 +61,12 @@ func (p *pingerLogger) Ping() (Packet, error) {
 }
 
 func (p *pingerLogger) formatPacket(pkt Packet) string {
-	return fmt.Sprintf("received %dB from %s in %dms", pkt.Size, pkt.Address.String(), (pkt.RTT / time.Millisecond))
+	s := fmt.Sprintf("received %dB from %s seq=%d in %dms", pkt.Size, pkt.Address.String(), pkt.Seq, (pkt.RTT / time.Millisecond))
+	if pkt.OutOfOrder {
+		s += " (out of order)"
+	}
+	if pkt.Duplicates > 0 {
+		s += fmt.Sprintf(" (+%d DUP!)", pkt.Duplicates)
+	}
+	return s
 }
@@ -14,21 +14,27 @@ var (
 	ErrNotConnected     = errors.New("not connected")
 )
 
+// Ping error.
+var (
+	ErrPingTimeout = errors.New("ping timed out")
+)
+
 // Driver handles the underlying pinging connection and provides metadata to Pinger.
 type Driver interface {
 	Address() net.Addr // Address to ping.
 
-	Connect(context.Context) error  // Connect to host.
-	Disconnect() error              // Disconnect from host.
-	Ping(*Timer) (RawPacket, error) // Ping host.
+	Connect(context.Context) error                   // Connect to host.
+	Disconnect() error                               // Disconnect from host.
+	Ping(context.Context, *Timer) (RawPacket, error) // Ping host. The ping must be abandoned when the context is done.
 }
 
 // Pinger reflects a standard pinging API.
 // See New() for detail on a standard, private implementation that uses a Driver for portability.
 type Pinger interface {
-	Connect(context.Context) error // Connect to host.
-	Disconnect() error             // Disconnect from host.
-	Ping() (Packet, error)         // Ping host.
+	Connect(context.Context) error               // Connect to host.
+	Disconnect() error                           // Disconnect from host.
+	Ping() (Packet, error)                       // Ping host.
+	PingContext(context.Context) (Packet, error) // Ping host, abandoning the ping if the context is done.
 }
 
 // Packet describes a fully processed packet built from other, constituent packet types.
@@ -41,6 +47,7 @@ type Packet struct {
 // PacketMeta describes metadata from the ping environment, including the request, rather than originating from the ping response.
 type PacketMeta struct {
 	Address net.Addr // Address of the host being pinged.
+	Source  net.Addr // Source that the ping was sent from, if configured. See SourceDriver.
 }
 
 // RawPacket describes the raw data available from a ping response.
@@ -48,12 +55,20 @@ type RawPacket struct {
 	Message []byte        // Message in response packet.
 	Size    int           // Size of response message in bytes.
 	TTL     time.Duration // TTL (Time To Live) of the packet.
+	Seq     int           // Sequence number of the ping.
+
+	Duplicates int  // Duplicate replies received since the previous packet.
+	Late       int  // Late replies, received after their ping was abandoned, since the previous packet.
+	OutOfOrder bool // OutOfOrder is true if a reply to a later ping was received before this packet.
+
+	Detail interface{} // Detail of the response specific to the driver, such as *TCPSYNDetail (optional).
 }
 
 // TimedPacket describes statistical data available for a ping response.
 type TimedPacket struct {
-	RTT  time.Duration // RTT (Round Trip Time) reflects the time between sending a ping and receiving a response.
-	Sent time.Time     // Sent time of request.
+	RTT   time.Duration // RTT (Round Trip Time) reflects the time between sending a ping and receiving a response.
+	Sent  time.Time     // Sent time of request.
+	Clock ClockSource   // Clock source used to measure RTT.
 }
 
 type pinger struct {
@@ -78,7 +93,7 @@ func (p *pinger) Connect(ctx context.Context) error {
 	if err == nil {
 		p.connected = true
 	}
-	return err
+	return Classify(err)
 }
 
 func (p *pinger) Disconnect() error {
@@ -93,19 +108,35 @@ func (p *pinger) Disconnect() error {
 }
 
 func (p *pinger) Ping() (Packet, error) {
+	return p.PingContext(context.Background())
+}
+
+// PingContext pings the host via the driver.
+// Errors are classified; see Error.
+// If the ping is abandoned because the context deadline is exceeded, ErrPingTimeout is returned. The connection remains open for further pings.
+// Other errors returned by the driver are kept, even if the deadline has since passed.
+func (p *pinger) PingContext(ctx context.Context) (Packet, error) {
 	timer := &Timer{}
-	raw, err := p.driver.Ping(timer)
+	raw, err := p.driver.Ping(ctx, timer)
 	if err != nil {
-		return Packet{}, err
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == context.DeadlineExceeded {
+			return Packet{}, Classify(ErrPingTimeout)
+		}
+		return Packet{}, Classify(err)
+	}
+	meta := PacketMeta{
+		Address: p.driver.Address(),
+	}
+	if sd, ok := p.driver.(SourceDriver); ok {
+		meta.Source = sd.Source()
 	}
 	packet := Packet{
-		RawPacket: raw,
-		PacketMeta: PacketMeta{
-			Address: p.driver.Address(),
-		},
+		RawPacket:  raw,
+		PacketMeta: meta,
 		TimedPacket: TimedPacket{
-			RTT:  timer.Elapsed(),
-			Sent: timer.Started,
+			RTT:   timer.Elapsed(),
+			Sent:  timer.Started,
+			Clock: timer.Clock,
 		},
 	}
 
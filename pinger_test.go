@@ -0,0 +1,78 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PingContext_Timeout(t *testing.T) {
+	a := assert.New(t)
+	pinger := Dummy(50 * time.Millisecond)
+
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := pinger.PingContext(ctx)
+	a.True(errors.Is(err, ErrPingTimeout))
+	a.True(errors.Is(err, ClassTimeout))
+
+	// connection should survive a timed out ping
+	_, err = pinger.Ping()
+	a.Nil(err)
+}
+
+// testSlowErrorDriver fails each ping with an error after a delay, regardless of the context.
+type testSlowErrorDriver struct {
+	delay time.Duration
+	err   error
+}
+
+func (d *testSlowErrorDriver) Address() net.Addr {
+	return tcpAddr("127.0.0.1:0")
+}
+
+func (d *testSlowErrorDriver) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (d *testSlowErrorDriver) Disconnect() error {
+	return nil
+}
+
+func (d *testSlowErrorDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	time.Sleep(d.delay)
+	return RawPacket{}, d.err
+}
+
+func Test_PingContext_ErrorAfterDeadline(t *testing.T) {
+	a := assert.New(t)
+	pinger := New(&testSlowErrorDriver{
+		delay: 20 * time.Millisecond,
+		err:   syscall.ECONNREFUSED,
+	})
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	// the driver's result is kept, even though the deadline passed before it returned
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := pinger.PingContext(ctx)
+	a.False(errors.Is(err, ErrPingTimeout), err)
+	a.True(errors.Is(err, ClassRefused), err)
+}
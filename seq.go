@@ -0,0 +1,135 @@
+package pinger
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of completed sequence numbers remembered for classifying stray replies.
+const seqHistorySize = 256
+
+type seqState int
+
+const (
+	seqAnswered seqState = iota
+	seqAbandoned
+)
+
+// seqReply wraps a raw packet with the time it was received.
+// If the reply reports an error, such as an ICMP error message, Err is set.
+type seqReply struct {
+	RawPacket
+	Received time.Time
+	Clock    ClockSource
+	Err      error
+}
+
+// sequences tracks in-flight requests by sequence number and dispatches replies to the pings waiting for them.
+// Replies to completed requests are not misattributed to other pings, but counted as duplicates or late replies.
+type sequences struct {
+	mut *sync.Mutex
+
+	pending map[int]chan seqReply
+	history map[int]seqState
+	ring    []int
+	next    int
+
+	highest  int
+	answered bool
+
+	duplicates int
+	late       int
+}
+
+func newSequences() *sequences {
+	return &sequences{
+		mut:     &sync.Mutex{},
+		pending: map[int]chan seqReply{},
+		history: map[int]seqState{},
+		ring:    make([]int, 0, seqHistorySize),
+	}
+}
+
+// Abandon an in-flight request, for example if the ping times out.
+func (s *sequences) Abandon(seq int) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.pending[seq]; ok {
+		delete(s.pending, seq)
+		s.record(seq, seqAbandoned)
+	}
+}
+
+// Add an in-flight request.
+// The returned channel receives the reply.
+func (s *sequences) Add(seq int) <-chan seqReply {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	c := make(chan seqReply, 1)
+	s.pending[seq] = c
+	delete(s.history, seq)
+	return c
+}
+
+// Dispatch a reply to the request it belongs to.
+func (s *sequences) Dispatch(seq int, reply seqReply) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if c, ok := s.pending[seq]; ok {
+		delete(s.pending, seq)
+		s.record(seq, seqAnswered)
+		if s.answered && seqBefore(seq, s.highest) {
+			reply.OutOfOrder = true
+		} else {
+			s.highest = seq
+			s.answered = true
+		}
+		c <- reply
+		return
+	}
+	state, ok := s.history[seq]
+	if !ok {
+		return
+	}
+	switch state {
+	case seqAnswered:
+		s.duplicates++
+	case seqAbandoned:
+		s.late++
+		// further replies to this request are duplicates
+		s.history[seq] = seqAnswered
+	}
+}
+
+// Pending determines whether a request is in flight.
+func (s *sequences) Pending(seq int) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	_, ok := s.pending[seq]
+	return ok
+}
+
+// Strays returns the number of duplicate and late replies received since the last call.
+func (s *sequences) Strays() (duplicates int, late int) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	duplicates, late = s.duplicates, s.late
+	s.duplicates, s.late = 0, 0
+	return
+}
+
+// seqBefore determines whether sequence number a precedes b, allowing for wraparound.
+func seqBefore(a, b int) bool {
+	return int16(a-b) < 0
+}
+
+func (s *sequences) record(seq int, state seqState) {
+	if len(s.ring) < seqHistorySize {
+		s.ring = append(s.ring, seq)
+	} else {
+		delete(s.history, s.ring[s.next])
+		s.ring[s.next] = seq
+	}
+	s.next = (s.next + 1) % seqHistorySize
+	s.history[seq] = state
+}
@@ -0,0 +1,45 @@
+package pinger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sequences(t *testing.T) {
+	a := assert.New(t)
+	s := newSequences()
+
+	r0 := s.Add(0)
+	r1 := s.Add(1)
+	r2 := s.Add(2)
+
+	// reply to 1 arrives first, then 0
+	s.Dispatch(1, seqReply{})
+	s.Dispatch(0, seqReply{})
+	a.False((<-r1).OutOfOrder)
+	a.True((<-r0).OutOfOrder)
+
+	// duplicate of 1
+	s.Dispatch(1, seqReply{})
+	// 2 abandoned, then its reply arrives late
+	s.Abandon(2)
+	s.Dispatch(2, seqReply{})
+	a.Len(r2, 0)
+	// unknown sequence is ignored
+	s.Dispatch(100, seqReply{})
+
+	duplicates, late := s.Strays()
+	a.Equal(1, duplicates)
+	a.Equal(1, late)
+	duplicates, late = s.Strays()
+	a.Equal(0, duplicates)
+	a.Equal(0, late)
+}
+
+func Test_Sequences_Wraparound(t *testing.T) {
+	a := assert.New(t)
+	a.True(seqBefore(65535, 0))
+	a.False(seqBefore(0, 65535))
+	a.True(seqBefore(1, 2))
+}
@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package pinger
+
+import (
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// bindToDevice binds c to a network interface.
+func bindToDevice(c interface{}, iface string) error {
+	return control(c, func(fd int) error {
+		return syscall.SetsockoptString(fd, syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+	})
+}
+
+// control calls fn with the file descriptor of c, which must be a syscall.Conn or syscall.RawConn.
+func control(c interface{}, fn func(fd int) error) error {
+	var rc syscall.RawConn
+	switch c := c.(type) {
+	case syscall.RawConn:
+		rc = c
+	case syscall.Conn:
+		var err error
+		if rc, err = c.SyscallConn(); err != nil {
+			return err
+		}
+	default:
+		return syscall.EINVAL
+	}
+	var ferr error
+	if err := rc.Control(func(fd uintptr) {
+		ferr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// enableKernelTimestamps asks the kernel to attach receive timestamps to packets read from c.
+func enableKernelTimestamps(c net.PacketConn) error {
+	return setsockoptInt(c, syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1)
+}
+
+// kernelTimestamp finds a receive timestamp in socket control messages.
+func kernelTimestamp(oob []byte) (time.Time, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, m := range msgs {
+		if m.Header.Level != syscall.SOL_SOCKET || m.Header.Type != syscall.SCM_TIMESTAMPNS {
+			continue
+		}
+		if len(m.Data) < int(unsafe.Sizeof(syscall.Timespec{})) {
+			continue
+		}
+		ts := (*syscall.Timespec)(unsafe.Pointer(&m.Data[0]))
+		return time.Unix(ts.Unix()), true
+	}
+	return time.Time{}, false
+}
+
+// setDontFragment prevents fragmentation of packets sent from c.
+func setDontFragment(c net.PacketConn, ipVersion int) error {
+	if ipVersion == 4 {
+		return setsockoptInt(c, syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+	}
+	return setsockoptInt(c, syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO)
+}
+
+func setsockoptInt(c interface{}, level, opt, value int) error {
+	return control(c, func(fd int) error {
+		return syscall.SetsockoptInt(fd, level, opt, value)
+	})
+}
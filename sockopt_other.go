@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package pinger
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+var errSockoptUnsupported = errors.New("socket option not supported on this platform")
+
+func bindToDevice(c interface{}, iface string) error {
+	return errSockoptUnsupported
+}
+
+func setDontFragment(c net.PacketConn, ipVersion int) error {
+	return errSockoptUnsupported
+}
+
+func enableKernelTimestamps(c net.PacketConn) error {
+	return errSockoptUnsupported
+}
+
+func kernelTimestamp(oob []byte) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,87 @@
+package pinger
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Source of pings.
+// On multi-homed hosts, use this to send pings from a specific address or network interface.
+type Source struct {
+	Addr      net.IP // Local address to send from (optional).
+	Interface string // Network interface to send from, bound with SO_BINDTODEVICE (optional, Linux only).
+}
+
+// SourceDriver may be implemented by a Driver to report the source of its pings.
+// The source is attached to each packet's metadata.
+type SourceDriver interface {
+	Source() net.Addr
+}
+
+// Network of source.
+func (s Source) Network() string {
+	return "ip"
+}
+
+// String representation of source, using zone notation for the interface if set (e.g. 192.0.2.1%eth0).
+func (s Source) String() string {
+	switch {
+	case s.Interface == "":
+		return s.Addr.String()
+	case s.Addr == nil:
+		return s.Interface
+	default:
+		return fmt.Sprintf("%s%%%s", s.Addr, s.Interface)
+	}
+}
+
+// addr returns the source as a net.Addr, or nil if it is not configured.
+func (s Source) addr() net.Addr {
+	if s.Addr == nil && s.Interface == "" {
+		return nil
+	}
+	return s
+}
+
+// bind a socket to the source interface.
+// This is compatible with net.Dialer.Control.
+func (s Source) bind(network, address string, c syscall.RawConn) error {
+	if s.Interface == "" {
+		return nil
+	}
+	return bindToDevice(c, s.Interface)
+}
+
+// dialer creates a dialer for connections from the source.
+func (s Source) dialer(network string) *net.Dialer {
+	d := &net.Dialer{
+		Control: s.bind,
+	}
+	if s.Addr != nil {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+			d.LocalAddr = &net.TCPAddr{IP: s.Addr}
+		case "udp", "udp4", "udp6":
+			d.LocalAddr = &net.UDPAddr{IP: s.Addr}
+		default:
+			d.LocalAddr = &net.IPAddr{IP: s.Addr}
+		}
+	}
+	return d
+}
+
+// listenAddr returns the source address for listening on a socket of an IP version.
+func (s Source) listenAddr(ipVersion int) (string, error) {
+	if s.Addr == nil {
+		return "", nil
+	}
+	if isIPv4(s.Addr) != (ipVersion == 4) {
+		return "", errSourceVersionMismatch(s.Addr, ipVersion)
+	}
+	return s.Addr.String(), nil
+}
+
+func errSourceVersionMismatch(addr net.IP, ipVersion int) error {
+	return fmt.Errorf("source address %s cannot be used for IPv%d", addr, ipVersion)
+}
@@ -12,73 +12,205 @@ type Report struct {
 	NumSuccessful int
 	NumFailed     int
 
-	MeanRTT time.Duration
+	NumDuplicates int // Duplicate replies, as reported by the driver.
+	NumLate       int // Late replies to abandoned pings, as reported by the driver.
+	NumOutOfOrder int // Replies received out of order, as reported by the driver.
+
+	Errors map[ErrorClass]int // Number of failed pings by error class.
+
+	// Loss bursts are runs of consecutive failed pings, in order of completion.
+	// These distinguish occasional random loss from outages with the same number of failed pings.
+	NumLossBursts    int
+	LongestLossBurst int
+	MeanLossBurst    float64
+	LossModel        LossModel // Gilbert-Elliott loss model fitted to the sequence of successful and failed pings.
+
+	MinRTT    time.Duration
+	MaxRTT    time.Duration
+	MeanRTT   time.Duration
+	MedianRTT time.Duration
+	StdDevRTT time.Duration // Population standard deviation of RTT, reported by ping(8) as mdev.
+
+	// Percentiles of RTT, using the nearest-rank method.
+	// Percentiles and the median are estimated to within 1% of the true value.
+	P90RTT time.Duration
+	P95RTT time.Duration
+	P99RTT time.Duration
+
+	// Jitter is the smoothed mean deviation of RTT between consecutive successful pings, in order of completion.
+	// This is calculated like interarrival jitter in RFC 3550, using RTT in place of transit time.
+	Jitter time.Duration
+
+	// Mean duration of each phase of successful HTTP() pings.
+	// Phases that did not occur in a ping, such as connecting when a connection is reused, count as zero.
+	MeanHTTPTiming HTTPTiming
 }
 
 // Stats aggregator.
 type Stats interface {
-	Calculate() Report // Calculate a new report based on current statistics.
+	Calculate() Report                    // Calculate a new report based on current statistics.
+	CalculateWindow() Report              // Calculate a report over the most recent pings. See StatsConfig.WindowSize.
+	CalculateRecent(time.Duration) Report // Calculate a report over pings within a recent duration. See StatsConfig.NumBuckets.
+	Buckets() []Bucket                    // Buckets reports statistics for each recent interval of time, oldest first. See StatsConfig.NumBuckets.
+	Samples() []Sample                    // Samples of recent pings, if configured. See StatsConfig.MaxSamples.
+
+	Reset()                      // Reset all statistics.
+	Snapshot() *Snapshot         // Snapshot of current statistics, which can be merged with other snapshots.
+	SnapshotAndReset() *Snapshot // SnapshotAndReset atomically takes a snapshot and resets all statistics, so no ping is counted twice or missed.
 }
 
-type statResult struct {
+// StatsConfig for a TrackWithConfig() aggregator.
+type StatsConfig struct {
+	// MaxSamples is the maximum number of recent ping results to keep as samples (optional, default none).
+	// Statistics are calculated in constant memory regardless of the number of samples.
+	MaxSamples int
+	// SampleMessages keeps packet messages in samples (optional).
+	// By default, messages are discarded to limit memory use.
+	SampleMessages bool
+
+	// WindowSize is the number of most recent pings reported by Stats.CalculateWindow() (optional, default none).
+	WindowSize int
+
+	// NumBuckets is the number of intervals of time reported by Stats.Buckets() (optional, default none).
+	// Stats.CalculateRecent() can report on durations up to the total time covered by buckets, to the resolution of the bucket interval.
+	// For example, 60 buckets with the default interval cover the last hour.
+	NumBuckets int
+	// BucketInterval is the duration of each bucket (optional, default 1 minute).
+	BucketInterval time.Duration
+}
+
+// Sample is the result of a ping.
+type Sample struct {
 	Packet Packet
 	Err    error
 }
 
 type stats struct {
-	agg []statResult
+	config StatsConfig
+	now    func() time.Time
+
+	mut     *sync.Mutex
+	acc     *accumulator
+	buckets *timeBuckets
+	samples *sampleRing
+	window  *sampleRing
 }
 
 type tracker struct {
 	next  Pinger
-	mut   *sync.Mutex
 	stats *stats
 }
 
 // Track statistics for a pinger.
 // For example, you might want to send five pings to a given host and get the average RTT.
 // This middleware provides a wrapped Pinger and a Stats aggregator that you can calculate reports from at any time.
+//
+// Statistics are kept in constant memory, so a pinger can be tracked indefinitely.
 func Track(next Pinger) (Pinger, Stats) {
-	s := &stats{
-		agg: []statResult{},
-	}
+	return TrackWithConfig(next, StatsConfig{})
+}
+
+// TrackWithConfig tracks statistics for a pinger with additional configuration.
+// See Track() for detail.
+func TrackWithConfig(next Pinger, cfg StatsConfig) (Pinger, Stats) {
+	s := newStats(cfg)
 	t := &tracker{
 		next:  next,
-		mut:   &sync.Mutex{},
 		stats: s,
 	}
 	return t, s
 }
 
-func (s *stats) Calculate() (rep Report) {
-	pkts, errs := s.collectTyped()
-	numPkts := len(pkts)
-	numErrs := len(errs)
+func newStats(cfg StatsConfig) *stats {
+	if cfg.BucketInterval <= 0 {
+		cfg.BucketInterval = defaultBucketInterval
+	}
+	if cfg.NumBuckets < 0 {
+		cfg.NumBuckets = 0
+	}
+	s := &stats{
+		config: cfg,
+		now:    time.Now,
+		mut:    &sync.Mutex{},
+	}
+	s.reset()
+	return s
+}
+
+func (s *stats) Buckets() []Bucket {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.buckets.Buckets(s.now())
+}
 
-	rep.NumPings = len(s.agg)
-	rep.NumSuccessful = numPkts
-	rep.NumFailed = numErrs
+func (s *stats) Calculate() Report {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.acc.Report()
+}
+
+// CalculateRecent reports pings within a recent duration.
+// The report includes all pings in each bucket that overlaps the duration, including the current bucket.
+func (s *stats) CalculateRecent(d time.Duration) Report {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.buckets.Since(s.now(), d).Report()
+}
 
-	if numPkts > 0 {
-		var totalRTT time.Duration = 0
-		for _, pkt := range pkts {
-			totalRTT += pkt.RTT
-		}
-		rep.MeanRTT = totalRTT / time.Duration(len(pkts))
+func (s *stats) CalculateWindow() Report {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	acc := newAccumulator()
+	for _, sample := range s.window.Samples() {
+		acc.Add(sample.Packet, sample.Err)
 	}
+	return acc.Report()
+}
 
-	return
+func (s *stats) Reset() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.reset()
 }
 
-func (s *stats) collectTyped() (pkts []Packet, errs []error) {
-	for _, result := range s.agg {
-		if result.Err != nil {
-			errs = append(errs, result.Err)
-		} else {
-			pkts = append(pkts, result.Packet)
-		}
+// Samples returns recent samples in the order they were recorded.
+func (s *stats) Samples() []Sample {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.samples.Samples()
+}
+
+func (s *stats) Snapshot() *Snapshot {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return &Snapshot{acc: s.acc.Clone()}
+}
+
+func (s *stats) SnapshotAndReset() *Snapshot {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	snap := &Snapshot{acc: s.acc}
+	s.reset()
+	return snap
+}
+
+// record the result of a ping.
+func (s *stats) record(pkt Packet, err error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.acc.Add(pkt, err)
+	s.buckets.Add(s.now(), pkt, err)
+
+	sample := Sample{
+		Packet: pkt,
+		Err:    err,
+	}
+	sample.Packet.Message = nil
+	s.window.Add(sample)
+	if s.config.SampleMessages {
+		sample.Packet.Message = pkt.Message
 	}
-	return
+	s.samples.Add(sample)
 }
 
 func (t *tracker) Connect(ctx context.Context) error {
@@ -90,14 +222,20 @@ func (t *tracker) Disconnect() error {
 }
 
 func (t *tracker) Ping() (Packet, error) {
-	pkt, err := t.next.Ping()
-
-	t.mut.Lock()
-	t.stats.agg = append(t.stats.agg, statResult{
-		Packet: pkt,
-		Err:    err,
-	})
-	t.mut.Unlock()
+	return t.PingContext(context.Background())
+}
 
+func (t *tracker) PingContext(ctx context.Context) (Packet, error) {
+	pkt, err := t.next.PingContext(ctx)
+	t.stats.record(pkt, err)
 	return pkt, err
 }
+
+// reset all statistics.
+// The caller must hold the lock, except when creating stats.
+func (s *stats) reset() {
+	s.acc = newAccumulator()
+	s.buckets = newTimeBuckets(s.config.BucketInterval, s.config.NumBuckets)
+	s.samples = newSampleRing(s.config.MaxSamples)
+	s.window = newSampleRing(s.config.WindowSize)
+}
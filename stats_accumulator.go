@@ -0,0 +1,218 @@
+package pinger
+
+import (
+	"math"
+	"time"
+)
+
+// accumulator of ping statistics in constant memory.
+// RTT variance is accumulated with Welford's algorithm, and percentiles are estimated with a durationSketch.
+type accumulator struct {
+	numPings      int
+	numSuccessful int
+	numFailed     int
+	numDuplicates int
+	numLate       int
+	numOutOfOrder int
+	errors        map[ErrorClass]int
+
+	minRTT time.Duration
+	maxRTT time.Duration
+	sumRTT time.Duration
+	m2RTT  float64 // Sum of squared differences from the mean RTT.
+	sketch *durationSketch
+
+	jitter  float64
+	lastRTT time.Duration
+
+	loss lossAccumulator
+
+	numHTTP       int
+	sumHTTPTiming HTTPTiming
+}
+
+func newAccumulator() *accumulator {
+	return &accumulator{
+		errors: map[ErrorClass]int{},
+		sketch: newDurationSketch(),
+	}
+}
+
+// Add the result of a ping.
+func (a *accumulator) Add(pkt Packet, err error) {
+	a.numPings++
+	a.loss.Add(err != nil)
+	if err != nil {
+		a.numFailed++
+		a.errors[ClassOf(err)]++
+		return
+	}
+
+	a.numSuccessful++
+	a.numDuplicates += pkt.Duplicates
+	a.numLate += pkt.Late
+	if pkt.OutOfOrder {
+		a.numOutOfOrder++
+	}
+
+	rtt := pkt.RTT
+	if a.numSuccessful == 1 {
+		a.minRTT, a.maxRTT = rtt, rtt
+	} else {
+		if rtt < a.minRTT {
+			a.minRTT = rtt
+		}
+		if rtt > a.maxRTT {
+			a.maxRTT = rtt
+		}
+		d := math.Abs(float64(rtt - a.lastRTT))
+		a.jitter += (d - a.jitter) / 16
+	}
+	a.lastRTT = rtt
+
+	n := float64(a.numSuccessful)
+	oldMean := 0.0
+	if a.numSuccessful > 1 {
+		oldMean = float64(a.sumRTT) / (n - 1)
+	}
+	a.sumRTT += rtt
+	a.m2RTT += (float64(rtt) - oldMean) * (float64(rtt) - float64(a.sumRTT)/n)
+	a.sketch.Add(rtt)
+
+	if detail, ok := pkt.Detail.(*HTTPDetail); ok {
+		a.numHTTP++
+		a.sumHTTPTiming.DNS += detail.Timing.DNS
+		a.sumHTTPTiming.Connect += detail.Timing.Connect
+		a.sumHTTPTiming.TLS += detail.Timing.TLS
+		a.sumHTTPTiming.TTFB += detail.Timing.TTFB
+		a.sumHTTPTiming.Transfer += detail.Timing.Transfer
+	}
+}
+
+// Clone the accumulator.
+func (a *accumulator) Clone() *accumulator {
+	c := *a
+	c.errors = make(map[ErrorClass]int, len(a.errors))
+	for class, n := range a.errors {
+		c.errors[class] = n
+	}
+	c.sketch = a.sketch.Clone()
+	return &c
+}
+
+// Append statistics accumulated over the pings immediately following this accumulator's pings, such as the next interval of time.
+// Loss bursts spanning both accumulators are joined.
+func (a *accumulator) Append(o *accumulator) {
+	a.merge(o)
+	a.loss.Append(&o.loss)
+}
+
+// Merge statistics accumulated separately into this accumulator, such as by another pinger probing the same host in parallel.
+// The other accumulator's pings are treated as more recent for jitter, but loss bursts are not joined.
+func (a *accumulator) Merge(o *accumulator) {
+	a.merge(o)
+	a.loss.Merge(&o.loss)
+}
+
+// merge statistics other than loss bursts, which depend on whether the accumulators are consecutive.
+func (a *accumulator) merge(o *accumulator) {
+	if o.numSuccessful > 0 {
+		if a.numSuccessful == 0 {
+			a.minRTT, a.maxRTT = o.minRTT, o.maxRTT
+		} else {
+			if o.minRTT < a.minRTT {
+				a.minRTT = o.minRTT
+			}
+			if o.maxRTT > a.maxRTT {
+				a.maxRTT = o.maxRTT
+			}
+		}
+		// combine variances with the parallel algorithm of Chan et al.
+		na, nb := float64(a.numSuccessful), float64(o.numSuccessful)
+		if na > 0 {
+			delta := float64(o.sumRTT)/nb - float64(a.sumRTT)/na
+			a.m2RTT += o.m2RTT + delta*delta*na*nb/(na+nb)
+		} else {
+			a.m2RTT = o.m2RTT
+		}
+		// jitter cannot be recombined exactly, so weight by number of pings
+		a.jitter = (a.jitter*na + o.jitter*nb) / (na + nb)
+		a.lastRTT = o.lastRTT
+		a.sumRTT += o.sumRTT
+		a.sketch.Merge(o.sketch)
+	}
+
+	a.numPings += o.numPings
+	a.numSuccessful += o.numSuccessful
+	a.numFailed += o.numFailed
+	a.numDuplicates += o.numDuplicates
+	a.numLate += o.numLate
+	a.numOutOfOrder += o.numOutOfOrder
+	for class, n := range o.errors {
+		a.errors[class] += n
+	}
+
+	a.numHTTP += o.numHTTP
+	a.sumHTTPTiming.DNS += o.sumHTTPTiming.DNS
+	a.sumHTTPTiming.Connect += o.sumHTTPTiming.Connect
+	a.sumHTTPTiming.TLS += o.sumHTTPTiming.TLS
+	a.sumHTTPTiming.TTFB += o.sumHTTPTiming.TTFB
+	a.sumHTTPTiming.Transfer += o.sumHTTPTiming.Transfer
+}
+
+// Report the accumulated statistics.
+func (a *accumulator) Report() (rep Report) {
+	rep.NumPings = a.numPings
+	rep.NumSuccessful = a.numSuccessful
+	rep.NumFailed = a.numFailed
+	rep.NumDuplicates = a.numDuplicates
+	rep.NumLate = a.numLate
+	rep.NumOutOfOrder = a.numOutOfOrder
+
+	rep.Errors = map[ErrorClass]int{}
+	for class, n := range a.errors {
+		rep.Errors[class] = n
+	}
+
+	rep.NumLossBursts = a.loss.numBursts
+	rep.LongestLossBurst = a.loss.longestBurst
+	rep.MeanLossBurst = a.loss.MeanBurst()
+	rep.LossModel = a.loss.Model()
+
+	if a.numSuccessful > 0 {
+		n := time.Duration(a.numSuccessful)
+		rep.MinRTT = a.minRTT
+		rep.MaxRTT = a.maxRTT
+		rep.MeanRTT = a.sumRTT / n
+		rep.StdDevRTT = time.Duration(math.Sqrt(a.m2RTT / float64(a.numSuccessful)))
+		rep.MedianRTT = a.quantile(0.5)
+		rep.P90RTT = a.quantile(0.9)
+		rep.P95RTT = a.quantile(0.95)
+		rep.P99RTT = a.quantile(0.99)
+		rep.Jitter = time.Duration(a.jitter)
+	}
+
+	if a.numHTTP > 0 {
+		n := time.Duration(a.numHTTP)
+		rep.MeanHTTPTiming = HTTPTiming{
+			DNS:      a.sumHTTPTiming.DNS / n,
+			Connect:  a.sumHTTPTiming.Connect / n,
+			TLS:      a.sumHTTPTiming.TLS / n,
+			TTFB:     a.sumHTTPTiming.TTFB / n,
+			Transfer: a.sumHTTPTiming.Transfer / n,
+		}
+	}
+	return
+}
+
+// quantile estimates an RTT quantile, bounded by the exact minimum and maximum RTT.
+func (a *accumulator) quantile(q float64) time.Duration {
+	d := a.sketch.Quantile(q)
+	if d < a.minRTT {
+		return a.minRTT
+	}
+	if d > a.maxRTT {
+		return a.maxRTT
+	}
+	return d
+}
@@ -0,0 +1,182 @@
+package pinger
+
+// LossModel is a Gilbert-Elliott model of packet loss fitted to a sequence of pings.
+// The model is a Markov chain with a good state, in which pings succeed, and a bad state, in which pings are lost.
+// This is the simple Gilbert form of the model, which assumes no loss in the good state and total loss in the bad state, so its parameters can be estimated directly from transitions between successful and failed pings.
+//
+// Independent random loss gives P+R close to 1, while outages give P+R much less than 1, as a ping's result then predicts the next.
+type LossModel struct {
+	P float64 // P is the probability of moving from the good state to the bad state, i.e. a ping failing after a successful ping.
+	R float64 // R is the probability of moving from the bad state to the good state, i.e. a ping succeeding after a failed ping.
+}
+
+// lossState of a ping in a sequence.
+type lossState int
+
+const (
+	lossStateNone lossState = iota
+	lossStateGood
+	lossStateBad
+)
+
+// lossAccumulator tracks runs of consecutive failed pings (loss bursts) and transitions between successful and failed pings.
+type lossAccumulator struct {
+	first lossState
+	last  lossState
+
+	numPings     int
+	numLost      int
+	numBursts    int
+	longestBurst int
+	leadingBurst int // Failed pings before the first successful ping.
+	currentBurst int // Failed pings since the last successful ping.
+
+	goodToBad    int // Successful pings followed by a failed ping.
+	goodFollowed int // Successful pings followed by any ping.
+	badToGood    int // Failed pings followed by a successful ping.
+	badFollowed  int // Failed pings followed by any ping.
+}
+
+// Add the result of a ping.
+func (l *lossAccumulator) Add(lost bool) {
+	state := lossStateGood
+	if lost {
+		state = lossStateBad
+	}
+	l.transition(l.last, state)
+	if l.first == lossStateNone {
+		l.first = state
+	}
+	l.last = state
+	l.numPings++
+
+	if !lost {
+		l.currentBurst = 0
+		return
+	}
+	l.numLost++
+	if l.currentBurst == 0 {
+		l.numBursts++
+	}
+	l.currentBurst++
+	if l.numLost == l.numPings {
+		l.leadingBurst = l.currentBurst
+	}
+	if l.currentBurst > l.longestBurst {
+		l.longestBurst = l.currentBurst
+	}
+}
+
+// MeanBurst is the mean length of loss bursts.
+func (l *lossAccumulator) MeanBurst() float64 {
+	if l.numBursts == 0 {
+		return 0
+	}
+	return float64(l.numLost) / float64(l.numBursts)
+}
+
+// Append loss accumulated over the pings immediately following this accumulator's pings.
+// A burst spanning both accumulators is counted once, and the transition between them is included in the model.
+func (l *lossAccumulator) Append(o *lossAccumulator) {
+	if o.numPings == 0 {
+		return
+	}
+	l.transition(l.last, o.first)
+	l.goodToBad += o.goodToBad
+	l.goodFollowed += o.goodFollowed
+	l.badToGood += o.badToGood
+	l.badFollowed += o.badFollowed
+
+	joined := l.currentBurst > 0 && o.leadingBurst > 0
+	if joined {
+		if span := l.currentBurst + o.leadingBurst; span > l.longestBurst {
+			l.longestBurst = span
+		}
+		l.numBursts--
+	}
+	if o.longestBurst > l.longestBurst {
+		l.longestBurst = o.longestBurst
+	}
+	if l.numLost == l.numPings {
+		// all of this accumulator's pings were lost, so the leading burst continues
+		l.leadingBurst += o.leadingBurst
+	}
+	if o.numLost == o.numPings {
+		l.currentBurst += o.currentBurst
+	} else {
+		l.currentBurst = o.currentBurst
+	}
+	if l.first == lossStateNone {
+		l.first = o.first
+	}
+	l.last = o.last
+	l.numPings += o.numPings
+	l.numLost += o.numLost
+	l.numBursts += o.numBursts
+}
+
+// Merge loss accumulated from an independent sequence of pings, such as another pinger probing the same host in parallel.
+// Bursts and transitions are combined without joining the sequences.
+// This accumulator's first and last pings are kept, unless it is empty.
+func (l *lossAccumulator) Merge(o *lossAccumulator) {
+	if o.numPings == 0 {
+		return
+	}
+	if l.numPings == 0 {
+		l.first, l.last = o.first, o.last
+		l.leadingBurst, l.currentBurst = o.leadingBurst, o.currentBurst
+	}
+	l.goodToBad += o.goodToBad
+	l.goodFollowed += o.goodFollowed
+	l.badToGood += o.badToGood
+	l.badFollowed += o.badFollowed
+	if o.longestBurst > l.longestBurst {
+		l.longestBurst = o.longestBurst
+	}
+	l.numPings += o.numPings
+	l.numLost += o.numLost
+	l.numBursts += o.numBursts
+}
+
+// Model fits a Gilbert-Elliott loss model to the transitions between pings.
+func (l *lossAccumulator) Model() (m LossModel) {
+	if l.goodFollowed > 0 {
+		m.P = float64(l.goodToBad) / float64(l.goodFollowed)
+	}
+	if l.badFollowed > 0 {
+		m.R = float64(l.badToGood) / float64(l.badFollowed)
+	}
+	return
+}
+
+// LossRate is the long-run proportion of pings lost according to the model.
+func (m LossModel) LossRate() float64 {
+	if m.P+m.R == 0 {
+		return 0
+	}
+	return m.P / (m.P + m.R)
+}
+
+// MeanBurst is the expected length of loss bursts according to the model.
+func (m LossModel) MeanBurst() float64 {
+	if m.R == 0 {
+		return 0
+	}
+	return 1 / m.R
+}
+
+// transition records the transition from one ping's state to the next.
+func (l *lossAccumulator) transition(from, to lossState) {
+	switch from {
+	case lossStateGood:
+		l.goodFollowed++
+		if to == lossStateBad {
+			l.goodToBad++
+		}
+	case lossStateBad:
+		l.badFollowed++
+		if to == lossStateGood {
+			l.badToGood++
+		}
+	}
+}
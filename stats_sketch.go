@@ -0,0 +1,91 @@
+package pinger
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Relative accuracy of quantiles estimated by a durationSketch.
+const sketchAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// durationSketch estimates quantiles of durations in bounded memory, based on DDSketch.
+// Each bucket counts durations within a range that grows by a constant factor, so the number of buckets only grows with the logarithm of the range of durations.
+// For example, durations between 1µs and 1h fit in fewer than 1,100 buckets.
+// Estimated quantiles are within 1% of the true value.
+type durationSketch struct {
+	buckets map[int]uint64
+	zeros   uint64 // Durations too short to bucket (less than 1ns).
+	count   uint64
+}
+
+func newDurationSketch() *durationSketch {
+	return &durationSketch{
+		buckets: map[int]uint64{},
+	}
+}
+
+// Add a duration to the sketch.
+func (s *durationSketch) Add(d time.Duration) {
+	s.count++
+	if d < 1 {
+		s.zeros++
+		return
+	}
+	s.buckets[int(math.Ceil(math.Log(float64(d))/sketchLogGamma))]++
+}
+
+// Clone the sketch.
+func (s *durationSketch) Clone() *durationSketch {
+	c := &durationSketch{
+		buckets: make(map[int]uint64, len(s.buckets)),
+		zeros:   s.zeros,
+		count:   s.count,
+	}
+	for k, n := range s.buckets {
+		c.buckets[k] = n
+	}
+	return c
+}
+
+// Merge another sketch into this sketch.
+func (s *durationSketch) Merge(o *durationSketch) {
+	for k, n := range o.buckets {
+		s.buckets[k] += n
+	}
+	s.zeros += o.zeros
+	s.count += o.count
+}
+
+// Quantile estimates the q-quantile (0-1) of durations, using the nearest-rank method.
+func (s *durationSketch) Quantile(q float64) time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank <= s.zeros {
+		return 0
+	}
+	keys := make([]int, 0, len(s.buckets))
+	for k := range s.buckets {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	seen := s.zeros
+	for _, k := range keys {
+		seen += s.buckets[k]
+		if seen >= rank {
+			// midpoint of bucket, minimising relative error
+			return time.Duration(2 * math.Pow(sketchGamma, float64(k)) / (sketchGamma + 1))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+package pinger
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Snapshot of statistics at a point in time.
+// Unlike a Report, snapshots can be merged to aggregate statistics from multiple pingers, such as the same host pinged from several workers, including percentiles.
+//
+// Snapshots can be encoded as JSON to merge statistics from other processes.
+type Snapshot struct {
+	acc *accumulator
+}
+
+// snapshotJSON is the JSON encoding of a Snapshot.
+type snapshotJSON struct {
+	NumPings      int                `json:"numPings"`
+	NumSuccessful int                `json:"numSuccessful"`
+	NumFailed     int                `json:"numFailed"`
+	NumDuplicates int                `json:"numDuplicates"`
+	NumLate       int                `json:"numLate"`
+	NumOutOfOrder int                `json:"numOutOfOrder"`
+	Errors        map[ErrorClass]int `json:"errors"`
+
+	MinRTT time.Duration `json:"minRTT"`
+	MaxRTT time.Duration `json:"maxRTT"`
+	SumRTT time.Duration `json:"sumRTT"`
+	M2RTT  float64       `json:"m2RTT"`
+
+	SketchBuckets map[int]uint64 `json:"sketchBuckets"`
+	SketchZeros   uint64         `json:"sketchZeros"`
+
+	Jitter  float64       `json:"jitter"`
+	LastRTT time.Duration `json:"lastRTT"`
+
+	Loss lossJSON `json:"loss"`
+
+	NumHTTP       int        `json:"numHTTP"`
+	SumHTTPTiming HTTPTiming `json:"sumHTTPTiming"`
+}
+
+// lossJSON is the JSON encoding of loss bursts in a Snapshot.
+type lossJSON struct {
+	First        lossState `json:"first"`
+	Last         lossState `json:"last"`
+	NumBursts    int       `json:"numBursts"`
+	LongestBurst int       `json:"longestBurst"`
+	LeadingBurst int       `json:"leadingBurst"`
+	CurrentBurst int       `json:"currentBurst"`
+	GoodToBad    int       `json:"goodToBad"`
+	GoodFollowed int       `json:"goodFollowed"`
+	BadToGood    int       `json:"badToGood"`
+	BadFollowed  int       `json:"badFollowed"`
+}
+
+// NewSnapshot creates an empty snapshot.
+// This is useful to merge other snapshots into.
+func NewSnapshot() *Snapshot {
+	return &Snapshot{acc: newAccumulator()}
+}
+
+// MergeSnapshots creates a new snapshot combining the statistics of all snapshots.
+func MergeSnapshots(snaps ...*Snapshot) *Snapshot {
+	merged := NewSnapshot()
+	for _, s := range snaps {
+		merged.Merge(s)
+	}
+	return merged
+}
+
+// MarshalJSON encodes the snapshot as JSON.
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	a := s.acc
+	return json.Marshal(snapshotJSON{
+		NumPings:      a.numPings,
+		NumSuccessful: a.numSuccessful,
+		NumFailed:     a.numFailed,
+		NumDuplicates: a.numDuplicates,
+		NumLate:       a.numLate,
+		NumOutOfOrder: a.numOutOfOrder,
+		Errors:        a.errors,
+		MinRTT:        a.minRTT,
+		MaxRTT:        a.maxRTT,
+		SumRTT:        a.sumRTT,
+		M2RTT:         a.m2RTT,
+		SketchBuckets: a.sketch.buckets,
+		SketchZeros:   a.sketch.zeros,
+		Jitter:        a.jitter,
+		LastRTT:       a.lastRTT,
+		Loss: lossJSON{
+			First:        a.loss.first,
+			Last:         a.loss.last,
+			NumBursts:    a.loss.numBursts,
+			LongestBurst: a.loss.longestBurst,
+			LeadingBurst: a.loss.leadingBurst,
+			CurrentBurst: a.loss.currentBurst,
+			GoodToBad:    a.loss.goodToBad,
+			GoodFollowed: a.loss.goodFollowed,
+			BadToGood:    a.loss.badToGood,
+			BadFollowed:  a.loss.badFollowed,
+		},
+		NumHTTP:       a.numHTTP,
+		SumHTTPTiming: a.sumHTTPTiming,
+	})
+}
+
+// Merge another snapshot into this snapshot.
+// The other snapshot is unaffected.
+//
+// Counts, RTT mean, standard deviation, minimum and maximum are merged exactly, and percentiles keep their accuracy.
+// Jitter cannot be merged exactly, so the merged jitter is the mean of each snapshot's jitter, weighted by the number of successful pings.
+// Loss bursts are merged as independent sequences of pings, so bursts in different snapshots are never joined.
+func (s *Snapshot) Merge(o *Snapshot) {
+	s.acc.Merge(o.acc)
+}
+
+// Report calculates a report from the snapshot.
+func (s *Snapshot) Report() Report {
+	return s.acc.Report()
+}
+
+// UnmarshalJSON decodes a snapshot from JSON.
+func (s *Snapshot) UnmarshalJSON(b []byte) error {
+	var j snapshotJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	a := newAccumulator()
+	a.numPings = j.NumPings
+	a.numSuccessful = j.NumSuccessful
+	a.numFailed = j.NumFailed
+	a.numDuplicates = j.NumDuplicates
+	a.numLate = j.NumLate
+	a.numOutOfOrder = j.NumOutOfOrder
+	for class, n := range j.Errors {
+		a.errors[class] = n
+	}
+	a.minRTT = j.MinRTT
+	a.maxRTT = j.MaxRTT
+	a.sumRTT = j.SumRTT
+	a.m2RTT = j.M2RTT
+	for k, n := range j.SketchBuckets {
+		a.sketch.buckets[k] = n
+		a.sketch.count += n
+	}
+	a.sketch.zeros = j.SketchZeros
+	a.sketch.count += j.SketchZeros
+	a.jitter = j.Jitter
+	a.lastRTT = j.LastRTT
+	a.loss = lossAccumulator{
+		first:        j.Loss.First,
+		last:         j.Loss.Last,
+		numPings:     j.NumPings,
+		numLost:      j.NumFailed,
+		numBursts:    j.Loss.NumBursts,
+		longestBurst: j.Loss.LongestBurst,
+		leadingBurst: j.Loss.LeadingBurst,
+		currentBurst: j.Loss.CurrentBurst,
+		goodToBad:    j.Loss.GoodToBad,
+		goodFollowed: j.Loss.GoodFollowed,
+		badToGood:    j.Loss.BadToGood,
+		badFollowed:  j.Loss.BadFollowed,
+	}
+	a.numHTTP = j.NumHTTP
+	a.sumHTTPTiming = j.SumHTTPTiming
+	s.acc = a
+	return nil
+}
@@ -2,6 +2,10 @@ package pinger
 
 import (
 	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -72,5 +76,319 @@ func Test_Stats_Errors(t *testing.T) {
 	a.Equal(10, report.NumPings)
 	a.Equal(0, report.NumSuccessful)
 	a.Equal(10, report.NumFailed)
+	a.Equal(10, report.Errors[ClassForced])
 	// we don't test any other stats here as they won't be meaningfully calculated without successful packets
 }
+
+func Test_Stats_Distribution(t *testing.T) {
+	a := assert.New(t)
+	s := newStats(StatsConfig{})
+	start := time.Now()
+	// RTTs of 1-100ms, recorded in reverse order
+	for i := 100; i > 0; i-- {
+		s.record(Packet{
+			TimedPacket: TimedPacket{
+				RTT:  time.Duration(i) * time.Millisecond,
+				Sent: start.Add(time.Duration(i) * time.Second),
+			},
+		}, nil)
+	}
+	s.record(Packet{}, ErrPingTimeout)
+
+	report := s.Calculate()
+	a.Equal(101, report.NumPings)
+	a.Equal(100, report.NumSuccessful)
+	a.Equal(1*time.Millisecond, report.MinRTT)
+	a.Equal(100*time.Millisecond, report.MaxRTT)
+	a.Equal(50500*time.Microsecond, report.MeanRTT)
+	// percentiles are estimated
+	a.InEpsilon(float64(50*time.Millisecond), float64(report.MedianRTT), sketchAccuracy)
+	a.InEpsilon(float64(90*time.Millisecond), float64(report.P90RTT), sketchAccuracy)
+	a.InEpsilon(float64(95*time.Millisecond), float64(report.P95RTT), sketchAccuracy)
+	a.InEpsilon(float64(99*time.Millisecond), float64(report.P99RTT), sketchAccuracy)
+	a.InDelta(float64(28866*time.Microsecond), float64(report.StdDevRTT), float64(time.Microsecond))
+	// consecutive RTTs differ by 1ms, so jitter converges on 1ms
+	a.InDelta(float64(998*time.Microsecond), float64(report.Jitter), float64(time.Microsecond))
+}
+
+func Test_Stats_Samples(t *testing.T) {
+	a := assert.New(t)
+	s := newStats(StatsConfig{MaxSamples: 3})
+	for i := 0; i < 5; i++ {
+		s.record(Packet{
+			RawPacket: RawPacket{Message: []byte("OK"), Seq: i},
+		}, nil)
+	}
+
+	samples := s.Samples()
+	if a.Len(samples, 3) {
+		for i, sample := range samples {
+			a.Equal(i+2, sample.Packet.Seq)
+			a.Nil(sample.Packet.Message)
+		}
+	}
+	a.Equal(5, s.Calculate().NumPings)
+
+	// no samples by default
+	s = newStats(StatsConfig{})
+	s.record(Packet{}, nil)
+	a.Empty(s.Samples())
+}
+
+func Test_durationSketch(t *testing.T) {
+	a := assert.New(t)
+	s := newDurationSketch()
+	rng := rand.New(rand.NewSource(0))
+	ds := make([]time.Duration, 10000)
+	for i := range ds {
+		// log-normal distribution centred on 10ms
+		ds[i] = time.Duration(math.Exp(rng.NormFloat64()) * float64(10*time.Millisecond))
+		s.Add(ds[i])
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i] < ds[j]
+	})
+
+	for _, q := range []float64{0.01, 0.5, 0.9, 0.99, 1} {
+		expected := ds[int(math.Ceil(q*float64(len(ds))))-1]
+		a.InEpsilon(float64(expected), float64(s.Quantile(q)), sketchAccuracy, "q=%f", q)
+	}
+	a.Less(len(s.buckets), 1000)
+}
+
+func testStatsPacket(rtt time.Duration) Packet {
+	return Packet{TimedPacket: TimedPacket{RTT: rtt}}
+}
+
+func Test_Stats_Window(t *testing.T) {
+	a := assert.New(t)
+	s := newStats(StatsConfig{WindowSize: 3})
+	for i := 1; i <= 5; i++ {
+		s.record(testStatsPacket(time.Duration(i)*time.Millisecond), nil)
+	}
+	s.record(Packet{}, ErrPingTimeout)
+
+	report := s.CalculateWindow()
+	a.Equal(3, report.NumPings)
+	a.Equal(1, report.NumFailed)
+	a.Equal(4*time.Millisecond, report.MinRTT)
+	a.Equal(5*time.Millisecond, report.MaxRTT)
+	a.Equal(6, s.Calculate().NumPings)
+}
+
+func Test_Stats_Buckets(t *testing.T) {
+	a := assert.New(t)
+	s := newStats(StatsConfig{NumBuckets: 3, BucketInterval: time.Minute})
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		return now
+	}
+
+	// 1 ping in the first minute, 2 in the second, and so on
+	for m := 1; m <= 4; m++ {
+		for i := 0; i < m; i++ {
+			s.record(testStatsPacket(time.Duration(m)*time.Millisecond), nil)
+		}
+		now = now.Add(time.Minute)
+	}
+	now = now.Add(-30 * time.Second)
+
+	buckets := s.Buckets()
+	if a.Len(buckets, 3) {
+		for i, b := range buckets {
+			a.Equal(time.Date(2021, 1, 1, 0, i+1, 0, 0, time.UTC), b.Start)
+			a.Equal(b.Start.Add(time.Minute), b.End)
+			a.Equal(i+2, b.NumPings)
+			a.Equal(time.Duration(i+2)*time.Millisecond, b.MeanRTT)
+		}
+	}
+
+	// the window starts partway into the 00:01 bucket, which is included
+	report := s.CalculateRecent(2 * time.Minute)
+	a.Equal(9, report.NumPings)
+	a.Equal(2*time.Millisecond, report.MinRTT)
+	a.Equal(4*time.Millisecond, report.MaxRTT)
+	report = s.CalculateRecent(30 * time.Second)
+	a.Equal(4, report.NumPings)
+	report = s.CalculateRecent(40 * time.Second)
+	a.Equal(7, report.NumPings)
+
+	// buckets are empty after an idle period
+	now = now.Add(time.Hour)
+	for _, b := range s.Buckets() {
+		a.Equal(0, b.NumPings)
+	}
+	a.Equal(0, s.CalculateRecent(time.Hour).NumPings)
+	a.Equal(10, s.Calculate().NumPings)
+}
+
+func Test_accumulator_Merge(t *testing.T) {
+	a := assert.New(t)
+	all, x, y := newAccumulator(), newAccumulator(), newAccumulator()
+	for i := 1; i <= 100; i++ {
+		pkt := testStatsPacket(time.Duration(i*i) * time.Microsecond)
+		all.Add(pkt, nil)
+		if i%3 == 0 {
+			x.Add(pkt, nil)
+		} else {
+			y.Add(pkt, nil)
+		}
+	}
+	x.Add(Packet{}, ErrPingTimeout)
+	x.Merge(y)
+
+	expected, merged := all.Report(), x.Report()
+	a.Equal(expected.NumSuccessful, merged.NumSuccessful)
+	a.Equal(1, merged.NumFailed)
+	a.Equal(expected.MinRTT, merged.MinRTT)
+	a.Equal(expected.MaxRTT, merged.MaxRTT)
+	a.Equal(expected.MeanRTT, merged.MeanRTT)
+	a.InDelta(float64(expected.StdDevRTT), float64(merged.StdDevRTT), 1)
+	a.Equal(expected.MedianRTT, merged.MedianRTT)
+	a.Equal(expected.P99RTT, merged.P99RTT)
+}
+
+func Test_Stats_SnapshotAndReset(t *testing.T) {
+	a := assert.New(t)
+	s := newStats(StatsConfig{MaxSamples: 10, WindowSize: 10, NumBuckets: 1})
+	for i := 1; i <= 3; i++ {
+		s.record(testStatsPacket(time.Duration(i)*time.Millisecond), nil)
+	}
+
+	snap := s.Snapshot()
+	s.record(testStatsPacket(4*time.Millisecond), nil)
+	a.Equal(3, snap.Report().NumPings)
+
+	snap = s.SnapshotAndReset()
+	a.Equal(4, snap.Report().NumPings)
+	a.Equal(0, s.Calculate().NumPings)
+	a.Equal(0, s.CalculateWindow().NumPings)
+	a.Equal(0, s.CalculateRecent(time.Minute).NumPings)
+	a.Empty(s.Samples())
+
+	// snapshot is unaffected by later pings
+	s.record(testStatsPacket(5*time.Millisecond), nil)
+	a.Equal(4, snap.Report().NumPings)
+	a.Equal(1, s.Calculate().NumPings)
+
+	s.Reset()
+	a.Equal(0, s.Calculate().NumPings)
+}
+
+func Test_Snapshot_Merge(t *testing.T) {
+	a := assert.New(t)
+	all := newStats(StatsConfig{})
+	workers := []*stats{newStats(StatsConfig{}), newStats(StatsConfig{})}
+	for i := 1; i <= 100; i++ {
+		pkt := testStatsPacket(time.Duration(i) * time.Millisecond)
+		all.record(pkt, nil)
+		workers[i%2].record(pkt, nil)
+	}
+	workers[0].record(Packet{}, ErrPingTimeout)
+	all.record(Packet{}, ErrPingTimeout)
+
+	// one worker's snapshot is sent over the wire
+	b, err := json.Marshal(workers[1].Snapshot())
+	if !a.Nil(err) {
+		return
+	}
+	remote := &Snapshot{}
+	if !a.Nil(json.Unmarshal(b, remote)) {
+		return
+	}
+
+	expected := all.Calculate()
+	merged := MergeSnapshots(workers[0].Snapshot(), remote).Report()
+	a.Equal(expected.NumPings, merged.NumPings)
+	a.Equal(expected.Errors, merged.Errors)
+	a.Equal(expected.MinRTT, merged.MinRTT)
+	a.Equal(expected.MaxRTT, merged.MaxRTT)
+	a.Equal(expected.MeanRTT, merged.MeanRTT)
+	a.InDelta(float64(expected.StdDevRTT), float64(merged.StdDevRTT), 1)
+	a.Equal(expected.MedianRTT, merged.MedianRTT)
+	a.Equal(expected.P90RTT, merged.P90RTT)
+	a.Equal(expected.P99RTT, merged.P99RTT)
+}
+
+func Test_Stats_LossBursts(t *testing.T) {
+	a := assert.New(t)
+	// same number of failed pings, lost either randomly or in one outage
+	random, outage := newAccumulator(), newAccumulator()
+	for i := 0; i < 300; i++ {
+		if i%10 == 5 {
+			random.Add(Packet{}, ErrPingTimeout)
+		} else {
+			random.Add(testStatsPacket(time.Millisecond), nil)
+		}
+		if i >= 100 && i < 130 {
+			outage.Add(Packet{}, ErrPingTimeout)
+		} else {
+			outage.Add(testStatsPacket(time.Millisecond), nil)
+		}
+	}
+
+	r, o := random.Report(), outage.Report()
+	a.Equal(r.NumFailed, o.NumFailed)
+	a.Equal(30, r.NumLossBursts)
+	a.Equal(1, r.LongestLossBurst)
+	a.Equal(1.0, r.MeanLossBurst)
+	a.InDelta(30.0/269, r.LossModel.P, 0.0001)
+	a.Equal(1.0, r.LossModel.R)
+	a.InDelta(0.1, r.LossModel.LossRate(), 0.001)
+
+	a.Equal(1, o.NumLossBursts)
+	a.Equal(30, o.LongestLossBurst)
+	a.Equal(30.0, o.MeanLossBurst)
+	a.InDelta(1.0/270, o.LossModel.P, 0.0001)
+	a.InDelta(1.0/30, o.LossModel.R, 0.0001)
+	a.InDelta(30, o.LossModel.MeanBurst(), 0.001)
+	a.InDelta(0.1, o.LossModel.LossRate(), 0.001)
+
+	// bursts spanning consecutive accumulators are counted once
+	for _, split := range []int{0, 100, 115, 130, 300} {
+		x, y := newAccumulator(), newAccumulator()
+		for i := 0; i < 300; i++ {
+			acc := x
+			if i >= split {
+				acc = y
+			}
+			if i >= 100 && i < 130 {
+				acc.Add(Packet{}, ErrPingTimeout)
+			} else {
+				acc.Add(testStatsPacket(time.Millisecond), nil)
+			}
+		}
+		x.Append(y)
+		m := x.Report()
+		a.Equal(o.NumLossBursts, m.NumLossBursts, split)
+		a.Equal(o.LongestLossBurst, m.LongestLossBurst, split)
+		a.Equal(o.LossModel, m.LossModel, split)
+	}
+}
+
+func Test_Snapshot_Merge_LossBursts(t *testing.T) {
+	a := assert.New(t)
+	// workers pinging in parallel, one ending and the other starting with an outage
+	workers := []*stats{newStats(StatsConfig{}), newStats(StatsConfig{})}
+	for i := 0; i < 20; i++ {
+		if i >= 15 {
+			workers[0].record(Packet{}, ErrPingTimeout)
+		} else {
+			workers[0].record(testStatsPacket(time.Millisecond), nil)
+		}
+		if i < 5 {
+			workers[1].record(Packet{}, ErrPingTimeout)
+		} else {
+			workers[1].record(testStatsPacket(time.Millisecond), nil)
+		}
+	}
+
+	merged := MergeSnapshots(workers[0].Snapshot(), workers[1].Snapshot()).Report()
+	a.Equal(10, merged.NumFailed)
+	a.Equal(2, merged.NumLossBursts)
+	a.Equal(5, merged.LongestLossBurst)
+	a.Equal(5.0, merged.MeanLossBurst)
+	// no transition between workers: 1 of 29 successful pings and 1 of 9 failed pings are followed by a change
+	a.InDelta(1.0/29, merged.LossModel.P, 0.0001)
+	a.InDelta(1.0/9, merged.LossModel.R, 0.0001)
+}
@@ -0,0 +1,127 @@
+package pinger
+
+import (
+	"time"
+)
+
+const defaultBucketInterval = time.Minute
+
+// Bucket reports statistics for pings recorded within an interval of time.
+type Bucket struct {
+	Start time.Time // Start of interval (inclusive).
+	End   time.Time // End of interval (exclusive).
+	Report
+}
+
+// sampleRing keeps a fixed number of the most recent samples.
+type sampleRing struct {
+	samples []Sample
+	next    int
+	size    int
+}
+
+// timeBuckets accumulate statistics in a ring of intervals.
+// Each bucket is identified by the start of its interval, and is reset when the ring wraps around to it.
+type timeBuckets struct {
+	interval time.Duration
+	starts   []time.Time
+	accs     []*accumulator
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{
+		samples: []Sample{},
+		size:    size,
+	}
+}
+
+// Add a sample, replacing the oldest sample if the ring is full.
+func (r *sampleRing) Add(sample Sample) {
+	if r.size <= 0 {
+		return
+	}
+	if len(r.samples) < r.size {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % r.size
+}
+
+// Samples in the order they were added.
+func (r *sampleRing) Samples() []Sample {
+	samples := make([]Sample, 0, len(r.samples))
+	samples = append(samples, r.samples[r.next:]...)
+	return append(samples, r.samples[:r.next]...)
+}
+
+func newTimeBuckets(interval time.Duration, n int) *timeBuckets {
+	return &timeBuckets{
+		interval: interval,
+		starts:   make([]time.Time, n),
+		accs:     make([]*accumulator, n),
+	}
+}
+
+// Add the result of a ping recorded at a time.
+func (b *timeBuckets) Add(t time.Time, pkt Packet, err error) {
+	if len(b.accs) == 0 {
+		return
+	}
+	b.bucket(t.Truncate(b.interval), true).Add(pkt, err)
+}
+
+// Buckets reports each interval up to and including the interval containing a time, oldest first.
+// Intervals without pings are included with an empty report.
+func (b *timeBuckets) Buckets(now time.Time) []Bucket {
+	buckets := make([]Bucket, 0, len(b.accs))
+	for i := len(b.accs) - 1; i >= 0; i-- {
+		start := now.Truncate(b.interval).Add(-time.Duration(i) * b.interval)
+		acc := b.bucket(start, false)
+		if acc == nil {
+			acc = newAccumulator()
+		}
+		buckets = append(buckets, Bucket{
+			Start:  start,
+			End:    start.Add(b.interval),
+			Report: acc.Report(),
+		})
+	}
+	return buckets
+}
+
+// Since merges the intervals overlapping the duration before a time.
+// Intervals are consecutive, so loss bursts spanning them are joined.
+func (b *timeBuckets) Since(now time.Time, d time.Duration) *accumulator {
+	merged := newAccumulator()
+	last := now.Truncate(b.interval)
+	n := int(last.Sub(now.Add(-d).Truncate(b.interval))/b.interval) + 1
+	if n > len(b.accs) {
+		n = len(b.accs)
+	}
+	for i := n - 1; i >= 0; i-- {
+		start := last.Add(-time.Duration(i) * b.interval)
+		if acc := b.bucket(start, false); acc != nil {
+			merged.Append(acc)
+		}
+	}
+	return merged
+}
+
+// bucket returns the accumulator for the interval beginning at start.
+// If the interval is not in the ring, it replaces the interval in its place if create is true, or otherwise nil is returned.
+func (b *timeBuckets) bucket(start time.Time, create bool) *accumulator {
+	i := int((start.UnixNano() / int64(b.interval)) % int64(len(b.accs)))
+	if i < 0 {
+		i += len(b.accs)
+	}
+	if b.accs[i] != nil && b.starts[i].Equal(start) {
+		return b.accs[i]
+	}
+	if !create {
+		return nil
+	}
+	b.starts[i] = start
+	b.accs[i] = newAccumulator()
+	return b.accs[i]
+}
@@ -0,0 +1,172 @@
+package pinger
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTCPBannerSize    = 1024
+	defaultTCPBannerTimeout = 5 * time.Second
+)
+
+// TCPConfig for a TCP() pinger.
+type TCPConfig struct {
+	Addr string // Address of host, as host:port.
+
+	Payload    []byte // Payload to send after connecting (optional).
+	Banner     bool   // Banner waits for the host to send data after connecting, and sending the payload if set (optional).
+	BannerSize int    // BannerSize is the maximum size of banner to read (optional, default 1024 bytes).
+
+	// BannerTimeout is the maximum time to wait for a banner after connecting (optional, default 5 seconds).
+	// If the host sends no banner in time, the ping fails with ClassTimeout.
+	BannerTimeout time.Duration
+
+	Source Source // Source of connections (optional).
+}
+
+type tcpAddr string
+
+type tcpDriver struct {
+	addr   *net.TCPAddr
+	config TCPConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	seq uint32
+}
+
+// TCP pinger.
+// Each ping opens a new TCP connection to the host, and the RTT reflects the time taken to complete the handshake.
+// The host's address is resolved when the pinger connects.
+// A refused connection fails with ClassRefused, distinct from a timeout.
+//
+// Optionally, the pinger can send a payload and wait for the host to respond with a banner, which is returned as the packet message.
+func TCP(cfg TCPConfig) (Pinger, error) {
+	if err := validateTCPConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return New(&tcpDriver{config: cfg}), nil
+}
+
+func (a tcpAddr) Network() string {
+	return "tcp"
+}
+
+func (a tcpAddr) String() string {
+	return string(a)
+}
+
+func (d *tcpDriver) Address() net.Addr {
+	return tcpAddr(d.config.Addr)
+}
+
+func (d *tcpDriver) Connect(ctx context.Context) error {
+	// resolve once, so the RTT does not include DNS lookups
+	addr, err := net.ResolveTCPAddr("tcp", d.config.Addr)
+	if err != nil {
+		return err
+	}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	d.addr = addr
+	return nil
+}
+
+func (d *tcpDriver) Disconnect() error {
+	d.cancel()
+	return nil
+}
+
+func (d *tcpDriver) Source() net.Addr {
+	return d.config.Source.addr()
+}
+
+func (d *tcpDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	rawc := make(chan RawPacket, 1)
+
+	go func() {
+		raw, err := d.send(ctx, timer)
+		if err != nil {
+			errc <- err
+		} else {
+			rawc <- raw
+		}
+	}()
+
+	select {
+	case <-d.ctx.Done():
+		return RawPacket{}, d.ctx.Err()
+	case <-ctx.Done():
+		return RawPacket{}, ctx.Err()
+	case err := <-errc:
+		return RawPacket{}, err
+	case raw := <-rawc:
+		return raw, nil
+	}
+}
+
+func (d *tcpDriver) send(ctx context.Context, timer *Timer) (RawPacket, error) {
+	seq := int(atomic.AddUint32(&d.seq, 1) - 1)
+	dialer := d.config.Source.dialer("tcp")
+	timer.Start()
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr.String())
+	timer.Stop()
+	if err != nil {
+		return RawPacket{}, err
+	}
+	// close connection when the ping completes or is abandoned
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	raw := RawPacket{
+		Message: []byte{},
+		Seq:     seq,
+	}
+	if len(d.config.Payload) > 0 {
+		if _, err := conn.Write(d.config.Payload); err != nil {
+			return RawPacket{}, err
+		}
+	}
+	if d.config.Banner {
+		if err := conn.SetReadDeadline(time.Now().Add(d.config.BannerTimeout)); err != nil {
+			return RawPacket{}, err
+		}
+		b := make([]byte, d.config.BannerSize)
+		n, err := conn.Read(b)
+		if err != nil && err != io.EOF {
+			return RawPacket{}, err
+		}
+		raw.Message = b[:n]
+		raw.Size = n
+	}
+	return raw, nil
+}
+
+func validateTCPConfig(cfg *TCPConfig) error {
+	// Addr required
+	if cfg.Addr == "" {
+		return ErrNoAddress
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return err
+	}
+	// BannerSize optional
+	if cfg.BannerSize <= 0 {
+		cfg.BannerSize = defaultTCPBannerSize
+	}
+	// BannerTimeout optional
+	if cfg.BannerTimeout <= 0 {
+		cfg.BannerTimeout = defaultTCPBannerTimeout
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TCP(t *testing.T) {
+	a := assert.New(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			b := make([]byte, 4)
+			if _, err := conn.Read(b); err == nil {
+				conn.Write(append([]byte("hello "), b...))
+			}
+			conn.Close()
+		}
+	}()
+
+	pinger, err := TCP(TCPConfig{
+		Addr:    l.Addr().String(),
+		Payload: []byte("ping"),
+		Banner:  true,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	for i := 0; i < 3; i++ {
+		packet, err := pinger.Ping()
+		if !a.Nil(err) {
+			return
+		}
+		a.Equal(i, packet.Seq)
+		a.Equal("hello ping", string(packet.Message))
+		a.Less(int64(0), int64(packet.RTT))
+	}
+}
+
+func Test_TCP_Refused(t *testing.T) {
+	a := assert.New(t)
+	// find a closed port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	pinger, err := TCP(TCPConfig{
+		Addr: addr,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	_, err = pinger.Ping()
+	a.True(errors.Is(err, ClassRefused), err)
+}
+
+func Test_TCP_BannerTimeout(t *testing.T) {
+	a := assert.New(t)
+	// host accepts connections but never sends a banner
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return
+	}
+	defer l.Close()
+
+	pinger, err := TCP(TCPConfig{
+		Addr:          l.Addr().String(),
+		Banner:        true,
+		BannerTimeout: 50 * time.Millisecond,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	_, err = pinger.Ping()
+	a.True(errors.Is(err, ClassTimeout), err)
+}
+
+func Test_TCP_Resolve(t *testing.T) {
+	a := assert.New(t)
+	pinger, err := TCP(TCPConfig{
+		Addr: "host.invalid:80",
+	})
+	if !a.Nil(err) {
+		return
+	}
+	// the address is resolved once when connecting, rather than in each ping
+	err = pinger.Connect(context.Background())
+	a.True(errors.Is(err, ClassDNS), err)
+}
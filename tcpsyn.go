@@ -0,0 +1,346 @@
+package pinger
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Size in bytes of SYN segment components.
+const (
+	tcpHeaderSize = 20
+	tcpMSSSize    = 4
+)
+
+// Range of local ports used by TCPSYN() pingers.
+const (
+	tcpSYNMinPort = 32768
+	tcpSYNMaxPort = 61000
+)
+
+const tcpSYNMSS = 1460
+
+// TCPFlags of a TCP segment.
+type TCPFlags uint8
+
+// TCP flags.
+const (
+	TCPFlagFIN TCPFlags = 1 << iota
+	TCPFlagSYN
+	TCPFlagRST
+	TCPFlagPSH
+	TCPFlagACK
+	TCPFlagURG
+	TCPFlagECE
+	TCPFlagCWR
+)
+
+var tcpFlagNames = []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG", "ECE", "CWR"}
+
+// TCPSYNConfig for a TCPSYN() pinger.
+type TCPSYNConfig struct {
+	Addr        *net.TCPAddr  // Address of host.
+	ReadTimeout time.Duration // ReadTimeout for packet receiver (optional).
+
+	// Source of SYN segments (optional).
+	// If no source address is set, it is chosen by the OS routing table.
+	Source Source
+}
+
+// TCPSYNDetail describes the response to a TCPSYN() ping.
+type TCPSYNDetail struct {
+	Flags TCPFlags // Flags of the response segment.
+	Open  bool     // Open is true if the host accepted the connection (SYN-ACK), or false if it was reset (RST).
+}
+
+type tcpSYNDriver struct {
+	config TCPSYNConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	conn      net.PacketConn
+	done      chan struct{}
+	err       error
+	src       net.IP
+	port      int
+	isn       uint32
+	seq       uint32
+	sequences *sequences
+}
+
+// TCPSYN pinger.
+// Each ping sends a SYN segment to the host and times the SYN-ACK or RST response, without completing the handshake.
+// Both responses are successful pings, distinguished by the packet's *TCPSYNDetail.
+//
+// This pinger requires a raw socket, and so the process must have root privileges or CAP_NET_RAW.
+func TCPSYN(cfg TCPSYNConfig) (Pinger, error) {
+	if err := validateTCPSYNConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return New(&tcpSYNDriver{config: cfg}), nil
+}
+
+// Has determines whether all of the flags in g are set.
+func (f TCPFlags) Has(g TCPFlags) bool {
+	return f&g == g
+}
+
+// String representation of flags, e.g. SYN,ACK.
+func (f TCPFlags) String() string {
+	names := []string{}
+	for i, name := range tcpFlagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (d *tcpSYNDriver) Address() net.Addr {
+	return d.config.Addr
+}
+
+func (d *tcpSYNDriver) Connect(ctx context.Context) error {
+	src, err := d.sourceIP()
+	if err != nil {
+		return err
+	}
+	network := "ip6:tcp"
+	version := 6
+	if isIPv4(d.config.Addr.IP) {
+		network = "ip4:tcp"
+		version = 4
+	}
+	if _, err := d.config.Source.listenAddr(version); err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket(network, src.String())
+	if err != nil {
+		return rawSocketError(err)
+	}
+	if d.config.Source.Interface != "" {
+		if err := bindToDevice(conn, d.config.Source.Interface); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	d.conn = conn
+	d.done = make(chan struct{})
+	d.err = nil
+	d.src = src
+	d.port = tcpSYNMinPort + rng.Intn(tcpSYNMaxPort-tcpSYNMinPort)
+	d.isn = rng.Uint32()
+	d.sequences = newSequences()
+	go d.recv()
+	return nil
+}
+
+func (d *tcpSYNDriver) Disconnect() error {
+	d.cancel()
+	return d.conn.Close()
+}
+
+func (d *tcpSYNDriver) Source() net.Addr {
+	return d.config.Source.addr()
+}
+
+func (d *tcpSYNDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	n := atomic.AddUint32(&d.seq, 1) - 1
+	seq := int(n & 0xffff)
+	replies := d.sequences.Add(seq)
+	segment := d.segment(d.isn + n)
+
+	timer.Start()
+	if err := d.send(segment); err != nil {
+		d.sequences.Abandon(seq)
+		return RawPacket{}, err
+	}
+
+	var err error
+	select {
+	case <-d.ctx.Done():
+		err = d.ctx.Err()
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-d.done:
+		err = d.err
+	case reply := <-replies:
+		timer.Stopped = reply.Received
+		timer.Clock = reply.Clock
+		raw := reply.RawPacket
+		raw.Seq = seq
+		raw.Duplicates, raw.Late = d.sequences.Strays()
+		return raw, nil
+	}
+	d.sequences.Abandon(seq)
+	return RawPacket{}, err
+}
+
+// handleSegment matches a received TCP segment to a ping, returning its sequence number.
+// The segment must be a SYN-ACK or RST from the host acknowledging one of our SYNs.
+func (d *tcpSYNDriver) handleSegment(b []byte, src net.IP) (int, *TCPSYNDetail, bool) {
+	if len(b) < tcpHeaderSize || !src.Equal(d.config.Addr.IP) {
+		return 0, nil, false
+	}
+	srcPort := int(binary.BigEndian.Uint16(b[0:2]))
+	dstPort := int(binary.BigEndian.Uint16(b[2:4]))
+	if srcPort != d.config.Addr.Port || dstPort != d.port {
+		return 0, nil, false
+	}
+	flags := TCPFlags(b[13])
+	if !flags.Has(TCPFlagSYN|TCPFlagACK) && !flags.Has(TCPFlagRST) {
+		return 0, nil, false
+	}
+	// the acknowledgement number is our SYN's sequence number plus one
+	n := binary.BigEndian.Uint32(b[8:12]) - 1 - d.isn
+	if n >= atomic.LoadUint32(&d.seq) {
+		return 0, nil, false
+	}
+	detail := &TCPSYNDetail{
+		Flags: flags,
+		Open:  flags.Has(TCPFlagSYN | TCPFlagACK),
+	}
+	return int(n & 0xffff), detail, true
+}
+
+func (d *tcpSYNDriver) recv() {
+	defer close(d.done)
+	b := make([]byte, readBufferSize)
+	for {
+		select {
+		case <-d.ctx.Done():
+			d.err = d.ctx.Err()
+			return
+		default:
+			if err := d.conn.SetReadDeadline(time.Now().Add(d.config.ReadTimeout)); err != nil {
+				d.err = err
+				return
+			}
+			n, addr, err := d.conn.ReadFrom(b)
+			// take receive time as close to the socket read as possible
+			received := time.Now()
+			if err != nil {
+				if netErr, ok := err.(*net.OpError); ok && netErr.Timeout() {
+					continue
+				}
+				d.err = err
+				return
+			}
+			ipAddr, ok := addr.(*net.IPAddr)
+			if !ok {
+				continue
+			}
+			seq, detail, ok := d.handleSegment(b[:n], ipAddr.IP)
+			if !ok {
+				// segments that cannot be matched to a ping are dropped
+				continue
+			}
+			message := make([]byte, n)
+			copy(message, b[:n])
+			d.sequences.Dispatch(seq, seqReply{
+				RawPacket: RawPacket{
+					Message: message,
+					Size:    n,
+					Detail:  detail,
+				},
+				Received: received,
+				Clock:    ClockUserspace,
+			})
+		}
+	}
+}
+
+// segment creates a SYN segment with the given sequence number.
+func (d *tcpSYNDriver) segment(isn uint32) []byte {
+	b := make([]byte, tcpHeaderSize+tcpMSSSize)
+	binary.BigEndian.PutUint16(b[0:2], uint16(d.port))
+	binary.BigEndian.PutUint16(b[2:4], uint16(d.config.Addr.Port))
+	binary.BigEndian.PutUint32(b[4:8], isn)
+	// data offset in 32-bit words
+	b[12] = byte(len(b)/4) << 4
+	b[13] = byte(TCPFlagSYN)
+	binary.BigEndian.PutUint16(b[14:16], 65535)
+	// maximum segment size option
+	b[20], b[21] = 2, tcpMSSSize
+	binary.BigEndian.PutUint16(b[22:24], tcpSYNMSS)
+	binary.BigEndian.PutUint16(b[16:18], tcpChecksum(d.src, d.config.Addr.IP, b))
+	return b
+}
+
+func (d *tcpSYNDriver) send(segment []byte) error {
+	dst := &net.IPAddr{IP: d.config.Addr.IP, Zone: d.config.Addr.Zone}
+	for {
+		_, err := d.conn.WriteTo(segment, dst)
+		if err != nil {
+			netErr, ok := err.(*net.OpError)
+			if ok && netErr.Err == syscall.ENOBUFS {
+				continue
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// sourceIP returns the local address of SYN segments, which is required to calculate the TCP checksum.
+// If the source address is not configured, it is determined by routing a UDP socket to the host.
+func (d *tcpSYNDriver) sourceIP() (net.IP, error) {
+	if d.config.Source.Addr != nil {
+		return d.config.Source.Addr, nil
+	}
+	// connecting a UDP socket sends no packets
+	conn, err := d.config.Source.dialer("udp").Dial("udp", d.config.Addr.String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// tcpChecksum calculates the checksum of a TCP segment, including the IPv4 or IPv6 pseudo-header.
+func tcpChecksum(src, dst net.IP, segment []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+	if isIPv4(dst) {
+		add(src.To4())
+		add(dst.To4())
+	} else {
+		add(src.To16())
+		add(dst.To16())
+	}
+	sum += syscall.IPPROTO_TCP
+	sum += uint32(len(segment))
+	add(segment)
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+func validateTCPSYNConfig(cfg *TCPSYNConfig) error {
+	// Addr required
+	if cfg.Addr == nil {
+		return ErrNoAddress
+	}
+	// ReadTimeout optional
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = defaultReadTimeout
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func doTestTCPSYN(t *testing.T, addr *net.TCPAddr) []Packet {
+	a := assert.New(t)
+	pinger, err := TCPSYN(TCPSYNConfig{
+		Addr: addr,
+	})
+	if !a.Nil(err) {
+		return nil
+	}
+	if err := pinger.Connect(context.Background()); err != nil {
+		if errors.Is(err, ErrRawSocketPermission) {
+			t.Skip(err)
+		}
+		a.Nil(err)
+		return nil
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	packets := []Packet{}
+	for i := 0; i < 3; i++ {
+		packet, err := pinger.Ping()
+		if !a.Nil(err) {
+			return nil
+		}
+		a.Equal(i, packet.Seq)
+		a.Less(int64(0), int64(packet.RTT))
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+func Test_TCPSYN_Open(t *testing.T) {
+	a := assert.New(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return
+	}
+	defer l.Close()
+
+	for _, packet := range doTestTCPSYN(t, l.Addr().(*net.TCPAddr)) {
+		detail, ok := packet.Detail.(*TCPSYNDetail)
+		if a.True(ok) {
+			a.True(detail.Open)
+			a.Equal("SYN,ACK", detail.Flags.String())
+		}
+	}
+}
+
+func Test_TCPSYN_Closed(t *testing.T) {
+	a := assert.New(t)
+	// find a closed port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+
+	for _, packet := range doTestTCPSYN(t, addr) {
+		detail, ok := packet.Detail.(*TCPSYNDetail)
+		if a.True(ok) {
+			a.False(detail.Open)
+			a.True(detail.Flags.Has(TCPFlagRST))
+		}
+	}
+}
+
+func Test_tcpChecksum(t *testing.T) {
+	a := assert.New(t)
+	src := net.ParseIP("192.0.2.1")
+	dst := net.ParseIP("192.0.2.2")
+	d := &tcpSYNDriver{
+		config: TCPSYNConfig{Addr: &net.TCPAddr{IP: dst, Port: 80}},
+		src:    src,
+		port:   40000,
+	}
+	segment := d.segment(1)
+	// a segment including its checksum sums to zero
+	a.Equal(uint16(0), tcpChecksum(src, dst, segment))
+}
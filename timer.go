@@ -2,10 +2,31 @@ package pinger
 
 import "time"
 
+// ClockSource identifies how the timings of a ping were measured.
+type ClockSource int
+
+// Clock sources.
+const (
+	ClockUserspace ClockSource = iota // Timings taken by the driver in userspace.
+	ClockKernel                       // Receive time reported by the kernel.
+)
+
 // Timer for a ping.
 type Timer struct {
 	Started time.Time
 	Stopped time.Time
+	Clock   ClockSource // Clock source of the stop time.
+}
+
+func (c ClockSource) String() string {
+	switch c {
+	case ClockUserspace:
+		return "userspace"
+	case ClockKernel:
+		return "kernel"
+	default:
+		return "unknown"
+	}
 }
 
 // Elapsed duration between the timer's start and stop times.
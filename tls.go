@@ -0,0 +1,199 @@
+package pinger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// TLSConfig for a TLS() pinger.
+type TLSConfig struct {
+	Addr string // Address of host, as host:port.
+
+	// TLS configuration of connections (optional).
+	// If the server name is not set, the host of Addr is used to verify the certificate.
+	TLS *tls.Config
+
+	// ExpiryWindow fails pings with a *TLSExpiryError if the leaf certificate expires within the window (optional).
+	ExpiryWindow time.Duration
+
+	Source Source // Source of connections (optional).
+}
+
+// TLSDetail describes the connection established by a TLS() ping.
+type TLSDetail struct {
+	Connect   time.Duration // Connect is the time taken to establish the TCP connection.
+	Handshake time.Duration // Handshake is the time taken to complete the TLS handshake after connecting.
+
+	Version     uint16 // TLS version, such as tls.VersionTLS13.
+	CipherSuite uint16 // Cipher suite, such as tls.TLS_AES_128_GCM_SHA256. See tls.CipherSuiteName.
+	ALPN        string // Application protocol negotiated with ALPN, if any.
+
+	Subject     pkix.Name // Subject of leaf certificate.
+	DNSNames    []string  // DNS names in the leaf certificate's subject alternative names.
+	IPAddresses []net.IP  // IP addresses in the leaf certificate's subject alternative names.
+	NotAfter    time.Time // Expiry time of leaf certificate.
+}
+
+// TLSExpiryError is returned by a TLS() ping when the leaf certificate expires within TLSConfig.ExpiryWindow.
+type TLSExpiryError struct {
+	Subject  pkix.Name // Subject of leaf certificate.
+	NotAfter time.Time // Expiry time of leaf certificate.
+}
+
+type tlsDriver struct {
+	addr   *net.TCPAddr
+	config TLSConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	seq uint32
+}
+
+// TLS pinger.
+// Each ping opens a new TCP connection to the host and completes a TLS handshake.
+// The RTT reflects the total time taken to connect and complete the handshake, while the packet's *TLSDetail reports each separately, along with the negotiated parameters and leaf certificate.
+// The host's address is resolved when the pinger connects, while the certificate is verified against the host name in the address.
+//
+// This pinger can monitor certificate expiry. See TLSConfig.ExpiryWindow.
+func TLS(cfg TLSConfig) (Pinger, error) {
+	if err := validateTLSConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return New(&tlsDriver{config: cfg}), nil
+}
+
+func (d *tlsDriver) Address() net.Addr {
+	return tcpAddr(d.config.Addr)
+}
+
+func (d *tlsDriver) Connect(ctx context.Context) error {
+	// resolve once, so connect time does not include DNS lookups
+	addr, err := net.ResolveTCPAddr("tcp", d.config.Addr)
+	if err != nil {
+		return err
+	}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	d.addr = addr
+	return nil
+}
+
+func (d *tlsDriver) Disconnect() error {
+	d.cancel()
+	return nil
+}
+
+func (d *tlsDriver) Source() net.Addr {
+	return d.config.Source.addr()
+}
+
+func (d *tlsDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	rawc := make(chan RawPacket, 1)
+
+	go func() {
+		raw, err := d.send(ctx, timer)
+		if err != nil {
+			errc <- err
+		} else {
+			rawc <- raw
+		}
+	}()
+
+	select {
+	case <-d.ctx.Done():
+		return RawPacket{}, d.ctx.Err()
+	case <-ctx.Done():
+		return RawPacket{}, ctx.Err()
+	case err := <-errc:
+		return RawPacket{}, err
+	case raw := <-rawc:
+		return raw, nil
+	}
+}
+
+func (d *tlsDriver) send(ctx context.Context, timer *Timer) (RawPacket, error) {
+	seq := int(atomic.AddUint32(&d.seq, 1) - 1)
+	dialer := d.config.Source.dialer("tcp")
+	timer.Start()
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr.String())
+	connected := time.Now()
+	if err != nil {
+		return RawPacket{}, err
+	}
+	tlsConn := tls.Client(conn, d.config.TLS)
+	// close connection when the ping completes or is abandoned
+	go func() {
+		<-ctx.Done()
+		tlsConn.Close()
+	}()
+
+	if err := tlsConn.Handshake(); err != nil {
+		if classify(err) == ClassUnknown {
+			// TLS alerts and other handshake failures are not otherwise classified
+			err = &Error{Class: ClassTLS, Err: err}
+		}
+		return RawPacket{}, err
+	}
+	timer.Stop()
+
+	state := tlsConn.ConnectionState()
+	detail := &TLSDetail{
+		Connect:     connected.Sub(timer.Started),
+		Handshake:   timer.Stopped.Sub(connected),
+		Version:     state.Version,
+		CipherSuite: state.CipherSuite,
+		ALPN:        state.NegotiatedProtocol,
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		detail.Subject = leaf.Subject
+		detail.DNSNames = leaf.DNSNames
+		detail.IPAddresses = leaf.IPAddresses
+		detail.NotAfter = leaf.NotAfter
+		if d.config.ExpiryWindow > 0 && timer.Stopped.Add(d.config.ExpiryWindow).After(leaf.NotAfter) {
+			return RawPacket{}, &TLSExpiryError{
+				Subject:  leaf.Subject,
+				NotAfter: leaf.NotAfter,
+			}
+		}
+	}
+	return RawPacket{
+		Message: []byte{},
+		Seq:     seq,
+		Detail:  detail,
+	}, nil
+}
+
+func (e *TLSExpiryError) Error() string {
+	return fmt.Sprintf("certificate for %s expires %s", e.Subject, e.NotAfter.Format(time.RFC3339))
+}
+
+func validateTLSConfig(cfg *TLSConfig) error {
+	// Addr required
+	if cfg.Addr == "" {
+		return ErrNoAddress
+	}
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return err
+	}
+	// TLS optional
+	if cfg.TLS == nil {
+		cfg.TLS = &tls.Config{}
+	} else {
+		cfg.TLS = cfg.TLS.Clone()
+	}
+	if cfg.TLS.ServerName == "" {
+		cfg.TLS.ServerName = host
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package pinger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TLS(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	pinger, err := TLS(TLSConfig{
+		Addr: srv.Listener.Addr().String(),
+		TLS: &tls.Config{
+			RootCAs:    roots,
+			NextProtos: []string{"h2"},
+		},
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	packet, err := pinger.Ping()
+	if !a.Nil(err) {
+		return
+	}
+	detail, ok := packet.Detail.(*TLSDetail)
+	if !a.True(ok) {
+		return
+	}
+	a.Less(int64(0), int64(detail.Connect))
+	a.Less(int64(0), int64(detail.Handshake))
+	a.Equal(detail.Connect+detail.Handshake, packet.RTT)
+	a.Equal(uint16(tls.VersionTLS13), detail.Version)
+	a.Equal("h2", detail.ALPN)
+	a.Equal(srv.Certificate().DNSNames, detail.DNSNames)
+	a.Equal(srv.Certificate().NotAfter, detail.NotAfter)
+}
+
+func Test_TLS_Expiry(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	pinger, err := TLS(TLSConfig{
+		Addr:         srv.Listener.Addr().String(),
+		TLS:          &tls.Config{InsecureSkipVerify: true},
+		ExpiryWindow: time.Until(srv.Certificate().NotAfter) + time.Hour,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	_, err = pinger.Ping()
+	a.True(errors.Is(err, ClassTLS), err)
+	expiryErr := &TLSExpiryError{}
+	if a.True(errors.As(err, &expiryErr)) {
+		a.Equal(srv.Certificate().NotAfter, expiryErr.NotAfter)
+	}
+}
+
+func Test_TLS_Untrusted(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	pinger, err := TLS(TLSConfig{
+		Addr: srv.Listener.Addr().String(),
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	_, err = pinger.Ping()
+	a.True(errors.Is(err, ClassTLS), err)
+}
+
+func Test_TLS_Resolve(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	pinger, err := TLS(TLSConfig{
+		Addr: "host.invalid:" + port,
+	})
+	if !a.Nil(err) {
+		return
+	}
+	err = pinger.Connect(context.Background())
+	a.True(errors.Is(err, ClassDNS), err)
+
+	// the certificate is verified against the host name, not the resolved address
+	pinger, err = TLS(TLSConfig{
+		Addr: "localhost:" + port,
+		TLS:  &tls.Config{RootCAs: roots},
+	})
+	if !a.Nil(err) {
+		return
+	}
+	if !a.Nil(pinger.Connect(context.Background())) {
+		return
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+	_, err = pinger.Ping()
+	hostErr := x509.HostnameError{}
+	a.True(errors.As(err, &hostErr), err)
+	a.Equal("localhost", hostErr.Host)
+}
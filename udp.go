@@ -0,0 +1,336 @@
+package pinger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+const (
+	defaultUDPReplySize = 1024
+	udpProtocol         = 17
+)
+
+// UDPConfig for a UDP() pinger.
+type UDPConfig struct {
+	Addr string // Address of host, as host:port.
+
+	Payload   []byte // Payload of datagram (optional).
+	ReplySize int    // ReplySize is the maximum size of reply to read (optional, default 1024 bytes).
+
+	// ICMP listens for ICMP error messages on a raw socket, which requires root privileges or CAP_NET_RAW (optional).
+	// Otherwise, the only error received is a port unreachable from the host, which the OS reports as a refused connection.
+	// With this enabled, errors reported by routers, such as host unreachable or TTL exceeded, fail the ping with an *ICMPError.
+	ICMP        bool
+	ReadTimeout time.Duration // ReadTimeout for ICMP packet receiver (optional).
+
+	Source Source // Source of datagrams (optional).
+}
+
+// UDPDetail describes the response to a UDP() ping.
+type UDPDetail struct {
+	Open bool // Open is true if the host replied, or false if it reported the port unreachable.
+}
+
+type udpAddr string
+
+type udpDriver struct {
+	config UDPConfig
+	addr   *net.UDPAddr
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	listener *udpICMPListener
+	seq      uint32
+}
+
+// udpICMPListener receives ICMP error messages in response to datagrams sent by a UDP() pinger.
+// Errors are dispatched to pings by the local port quoted in the message.
+type udpICMPListener struct {
+	addr            *net.UDPAddr
+	packetConn      *icmp.PacketConn
+	protocolHandler icmpProtocolHandler
+	readTimeout     time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	mut     *sync.Mutex
+	pending map[int]chan seqReply
+}
+
+// UDP pinger.
+// Each ping sends a datagram from a new socket and waits for the host to reply.
+// If the host reports the port is unreachable, the ping succeeds as the host is alive, but the packet's *UDPDetail reports the port is closed.
+//
+// A host that does not reply and does not report an error cannot be distinguished from packet loss, so pings should have a deadline. See Pinger.PingContext.
+func UDP(cfg UDPConfig) (Pinger, error) {
+	if err := validateUDPConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return New(&udpDriver{config: cfg}), nil
+}
+
+func (a udpAddr) Network() string {
+	return "udp"
+}
+
+func (a udpAddr) String() string {
+	return string(a)
+}
+
+func (d *udpDriver) Address() net.Addr {
+	return udpAddr(d.config.Addr)
+}
+
+func (d *udpDriver) Connect(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp", d.config.Addr)
+	if err != nil {
+		return err
+	}
+	if d.config.ICMP {
+		l, err := listenUDPICMP(addr, d.config)
+		if err != nil {
+			return err
+		}
+		d.listener = l
+	}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	d.addr = addr
+	return nil
+}
+
+func (d *udpDriver) Disconnect() error {
+	d.cancel()
+	if d.listener != nil {
+		return d.listener.close()
+	}
+	return nil
+}
+
+func (d *udpDriver) Source() net.Addr {
+	return d.config.Source.addr()
+}
+
+func (d *udpDriver) Ping(ctx context.Context, timer *Timer) (RawPacket, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	seq := int(atomic.AddUint32(&d.seq, 1) - 1)
+	conn, err := d.config.Source.dialer("udp").DialContext(ctx, "udp", d.addr.String())
+	if err != nil {
+		return RawPacket{}, err
+	}
+	// closing the connection also stops the exchange
+	defer conn.Close()
+
+	var icmpErrors <-chan seqReply
+	var done <-chan struct{}
+	if d.listener != nil {
+		port := conn.LocalAddr().(*net.UDPAddr).Port
+		icmpErrors = d.listener.add(port)
+		defer d.listener.remove(port)
+		done = d.listener.done
+	}
+
+	replies := make(chan seqReply, 1)
+	timer.Start()
+	go func() {
+		replies <- d.exchange(conn)
+	}()
+
+	var reply seqReply
+	select {
+	case <-d.ctx.Done():
+		return RawPacket{}, d.ctx.Err()
+	case <-ctx.Done():
+		return RawPacket{}, ctx.Err()
+	case <-done:
+		return RawPacket{}, d.listener.err
+	case reply = <-icmpErrors:
+	case reply = <-replies:
+	}
+
+	if reply.Err != nil {
+		if !isPortUnreachable(reply.Err) {
+			return RawPacket{}, reply.Err
+		}
+		reply.RawPacket = RawPacket{
+			Message: []byte{},
+			Detail:  &UDPDetail{Open: false},
+		}
+	}
+	timer.Stopped = reply.Received
+	timer.Clock = reply.Clock
+	raw := reply.RawPacket
+	raw.Seq = seq
+	return raw, nil
+}
+
+// exchange sends the payload on a connected socket and reads the reply.
+func (d *udpDriver) exchange(conn net.Conn) seqReply {
+	if _, err := conn.Write(d.config.Payload); err != nil {
+		return seqReply{Err: err, Received: time.Now()}
+	}
+	b := make([]byte, d.config.ReplySize)
+	n, err := conn.Read(b)
+	// take receive time as close to the socket read as possible
+	received := time.Now()
+	if err != nil {
+		return seqReply{Err: err, Received: received}
+	}
+	return seqReply{
+		RawPacket: RawPacket{
+			Message: b[:n],
+			Size:    n,
+			Detail:  &UDPDetail{Open: true},
+		},
+		Received: received,
+		Clock:    ClockUserspace,
+	}
+}
+
+func listenUDPICMP(addr *net.UDPAddr, cfg UDPConfig) (*udpICMPListener, error) {
+	h := newProtocolHandler(addr.IP)
+	listenAddr, err := cfg.Source.listenAddr(h.Version())
+	if err != nil {
+		return nil, err
+	}
+	c, err := h.Listen(listenAddr, true)
+	if err != nil {
+		return nil, rawSocketError(err)
+	}
+	if cfg.Source.Interface != "" {
+		if err := bindToDevice(underlyingConn(c), cfg.Source.Interface); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	l := &udpICMPListener{
+		addr:            addr,
+		packetConn:      c,
+		protocolHandler: h,
+		readTimeout:     cfg.ReadTimeout,
+		done:            make(chan struct{}),
+		mut:             &sync.Mutex{},
+		pending:         map[int]chan seqReply{},
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	go l.recv()
+	return l, nil
+}
+
+// add a ping sent from a local port.
+// The returned channel receives an ICMP error message in response to the ping.
+func (l *udpICMPListener) add(port int) <-chan seqReply {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	c := make(chan seqReply, 1)
+	l.pending[port] = c
+	return c
+}
+
+func (l *udpICMPListener) close() error {
+	l.cancel()
+	return l.packetConn.Close()
+}
+
+// handlePacket matches an ICMP error message to the local port of the datagram it quotes.
+// The quote must include at least the source and destination ports of the UDP header.
+func (l *udpICMPListener) handlePacket(pkt icmpPacket) (int, *ICMPError, error) {
+	msg, err := l.protocolHandler.Parse(pkt.Message)
+	if err != nil {
+		return 0, nil, err
+	}
+	quote, ok := parseICMPQuote(msg)
+	if !ok || quote.Protocol != udpProtocol || len(quote.Payload) < 4 || !quote.Dst.Equal(l.addr.IP) {
+		return 0, nil, ErrICMPIgnoredPacket
+	}
+	if dstPort := int(binary.BigEndian.Uint16(quote.Payload[2:4])); dstPort != l.addr.Port {
+		return 0, nil, ErrICMPIgnoredPacket
+	}
+	icmpErr := &ICMPError{
+		Type: msg.Type,
+		Code: msg.Code,
+		From: pkt.Src,
+	}
+	return int(binary.BigEndian.Uint16(quote.Payload[0:2])), icmpErr, nil
+}
+
+func (l *udpICMPListener) recv() {
+	defer close(l.done)
+	for {
+		select {
+		case <-l.ctx.Done():
+			l.err = l.ctx.Err()
+			return
+		default:
+			if err := l.packetConn.SetReadDeadline(time.Now().Add(l.readTimeout)); err != nil {
+				l.err = err
+				return
+			}
+			pkt, err := l.protocolHandler.Read(l.packetConn, readBufferSize)
+			received := time.Now()
+			if err != nil {
+				if netErr, ok := err.(*net.OpError); ok && netErr.Timeout() {
+					continue
+				}
+				l.err = err
+				return
+			}
+			port, icmpErr, err := l.handlePacket(pkt)
+			if err != nil {
+				// packets that cannot be matched to a ping are dropped
+				continue
+			}
+			l.mut.Lock()
+			if c, ok := l.pending[port]; ok {
+				delete(l.pending, port)
+				c <- seqReply{Err: icmpErr, Received: received}
+			}
+			l.mut.Unlock()
+		}
+	}
+}
+
+// remove a ping sent from a local port.
+func (l *udpICMPListener) remove(port int) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	delete(l.pending, port)
+}
+
+// isPortUnreachable determines whether an error indicates the host is alive, but the port is closed.
+func isPortUnreachable(err error) bool {
+	return errors.Is(err, ErrICMPPortUnreachable) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func validateUDPConfig(cfg *UDPConfig) error {
+	// Addr required
+	if cfg.Addr == "" {
+		return ErrNoAddress
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return err
+	}
+	// ReplySize optional
+	if cfg.ReplySize <= 0 {
+		cfg.ReplySize = defaultUDPReplySize
+	}
+	// ReadTimeout optional
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = defaultReadTimeout
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package pinger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func doTestUDP(t *testing.T, cfg UDPConfig) []Packet {
+	a := assert.New(t)
+	pinger, err := UDP(cfg)
+	if !a.Nil(err) {
+		return nil
+	}
+	if err := pinger.Connect(context.Background()); err != nil {
+		if errors.Is(err, ErrRawSocketPermission) {
+			t.Skip(err)
+		}
+		a.Nil(err)
+		return nil
+	}
+	defer func() {
+		a.Nil(pinger.Disconnect())
+	}()
+
+	packets := []Packet{}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		packet, err := pinger.PingContext(ctx)
+		cancel()
+		if !a.Nil(err) {
+			return nil
+		}
+		a.Equal(i, packet.Seq)
+		a.Less(int64(0), int64(packet.RTT))
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+func Test_UDP(t *testing.T) {
+	a := assert.New(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !a.Nil(err) {
+		return
+	}
+	defer conn.Close()
+	go func() {
+		b := make([]byte, 16)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(append([]byte("hello "), b[:n]...), addr)
+		}
+	}()
+
+	packets := doTestUDP(t, UDPConfig{
+		Addr:    conn.LocalAddr().String(),
+		Payload: []byte("ping"),
+	})
+	for _, packet := range packets {
+		a.Equal("hello ping", string(packet.Message))
+		detail, ok := packet.Detail.(*UDPDetail)
+		if a.True(ok) {
+			a.True(detail.Open)
+		}
+	}
+}
+
+func Test_UDP_Closed(t *testing.T) {
+	// find a closed port
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	for _, listenICMP := range []bool{false, true} {
+		packets := doTestUDP(t, UDPConfig{
+			Addr: addr,
+			ICMP: listenICMP,
+		})
+		for _, packet := range packets {
+			detail, ok := packet.Detail.(*UDPDetail)
+			if assert.True(t, ok) {
+				assert.False(t, detail.Open)
+			}
+		}
+	}
+}
+
+func testUDPPortUnreachable(a *assert.Assertions, dst net.IP, srcPort, dstPort int) []byte {
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dstPort))
+	h := &ipv4.Header{
+		Version:  ipv4.Version,
+		Len:      ipv4.HeaderLen,
+		TotalLen: ipv4.HeaderLen + len(udp),
+		TTL:      64,
+		Protocol: udpProtocol,
+		Src:      net.ParseIP("10.0.0.1"),
+		Dst:      dst,
+	}
+	hb, err := h.Marshal()
+	if !a.Nil(err) {
+		return nil
+	}
+	msg := &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3, Body: &icmp.DstUnreach{Data: append(hb, udp...)}}
+	b, err := msg.Marshal(nil)
+	a.Nil(err)
+	return b
+}
+
+func Test_udpICMPListener(t *testing.T) {
+	a := assert.New(t)
+	dst := net.ParseIP("192.0.2.1")
+	l := &udpICMPListener{
+		addr:            &net.UDPAddr{IP: dst, Port: 53},
+		protocolHandler: &icmpIPv4Handler{},
+	}
+
+	msg := testUDPPortUnreachable(a, dst, 40000, 53)
+	port, icmpErr, err := l.handlePacket(icmpPacket{Message: msg, Src: dst})
+	if a.Nil(err) {
+		a.Equal(40000, port)
+		a.True(errors.Is(icmpErr, ErrICMPPortUnreachable))
+		a.True(isPortUnreachable(icmpErr))
+	}
+
+	// datagram to another port
+	msg = testUDPPortUnreachable(a, dst, 40000, 54)
+	_, _, err = l.handlePacket(icmpPacket{Message: msg, Src: dst})
+	a.Equal(ErrICMPIgnoredPacket, err)
+}